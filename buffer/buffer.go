@@ -2,8 +2,6 @@ package buffer
 
 import (
 	"encoding/json"
-	"fmt"
-	"os"
 	"strconv"
 	"time"
 	"unsafe"
@@ -14,76 +12,271 @@ const _size = 1024 // by default, create 1 KiB buffers
 // Buffer is a thin wrapper around a byte slice. It's intended to be pooled, so
 // the only way to construct one is via a Pool.
 type Buffer struct {
-	bs   []byte
-	pool Pool
+	bs       []byte
+	off      int  // read cursor: bs[off:] is unread
+	lastRead bool // the last read consumed at least one byte
+	pool     Pool
+	depth    int // open JSON objects and arrays
+	debug    debugState
 }
 
 // AppendByte writes a single byte to the Buffer.
 func (b *Buffer) AppendByte(v byte) {
+	b.checkUse()
 	b.bs = append(b.bs, v)
 }
 
 // AppendString writes a string to the Buffer.
 func (b *Buffer) AppendString(s string) {
+	b.checkUse()
 	b.bs = append(b.bs, s...)
 }
 
 // AppendInt appends an integer to the underlying buffer (assuming base 10).
 func (b *Buffer) AppendInt(i int64) {
+	b.checkUse()
 	b.bs = strconv.AppendInt(b.bs, i, 10)
 }
 
 // AppendTime appends the time formatted using the specified layout.
 func (b *Buffer) AppendTime(t time.Time, layout string) {
+	b.checkUse()
 	b.bs = t.AppendFormat(b.bs, layout)
 }
 
 // AppendUint appends an unsigned integer to the underlying buffer (assuming
 // base 10).
 func (b *Buffer) AppendUint(i uint64) {
+	b.checkUse()
 	b.bs = strconv.AppendUint(b.bs, i, 10)
 }
 
 // AppendBool appends a bool to the underlying buffer.
 func (b *Buffer) AppendBool(v bool) {
+	b.checkUse()
 	b.bs = strconv.AppendBool(b.bs, v)
 }
 
 // AppendFloat appends a float to the underlying buffer. It doesn't quote NaN
 // or +/- Inf.
 func (b *Buffer) AppendFloat(f float64, bitSize int) {
+	b.checkUse()
 	b.bs = strconv.AppendFloat(b.bs, f, 'f', -1, bitSize)
 }
 
-// Len returns the length of the underlying byte slice.
+// AppendFloatFmt appends a float formatted like strconv.FormatFloat with the
+// given format, precision and bit size.
+func (b *Buffer) AppendFloatFmt(f float64, fmt byte, prec, bitSize int) {
+	b.checkUse()
+	b.bs = strconv.AppendFloat(b.bs, f, fmt, prec, bitSize)
+}
+
+// AppendIntHex appends an integer in lowercase hexadecimal, without a
+// prefix.
+func (b *Buffer) AppendIntHex(i int64) {
+	b.checkUse()
+	b.bs = strconv.AppendInt(b.bs, i, 16)
+}
+
+// AppendUintHex appends an unsigned integer in lowercase hexadecimal,
+// without a prefix.
+func (b *Buffer) AppendUintHex(i uint64) {
+	b.checkUse()
+	b.bs = strconv.AppendUint(b.bs, i, 16)
+}
+
+// AppendIntBinary appends an integer in binary, without a prefix.
+func (b *Buffer) AppendIntBinary(i int64) {
+	b.checkUse()
+	b.bs = strconv.AppendInt(b.bs, i, 2)
+}
+
+// AppendUintBinary appends an unsigned integer in binary, without a prefix.
+func (b *Buffer) AppendUintBinary(i uint64) {
+	b.checkUse()
+	b.bs = strconv.AppendUint(b.bs, i, 2)
+}
+
+// AppendDuration appends d as a number of units, such as time.Millisecond
+// or time.Second, with as many decimals as needed ("1.5" for 1500ms in
+// seconds). If unit is not positive, AppendDuration panics.
+func (b *Buffer) AppendDuration(d, unit time.Duration) {
+	b.checkUse()
+	if unit <= 0 {
+		panic("buffer: non-positive duration unit")
+	}
+	if d%unit == 0 {
+		b.bs = strconv.AppendInt(b.bs, int64(d/unit), 10)
+		return
+	}
+	b.bs = strconv.AppendFloat(b.bs, float64(d)/float64(unit), 'f', -1, 64)
+}
+
+// appendDurationString appends d formatted like d.String(), such as
+// "1h2m0.5s", without building the intermediate string.
+func (b *Buffer) appendDurationString(d time.Duration) {
+	var buf [32]byte
+	w := len(buf)
+	u := uint64(d)
+	if d < 0 {
+		u = -u
+	}
+	if u < uint64(time.Second) {
+		// Less than a second is written in a smaller unit, with a
+		// fraction, so that 1.5ms is "1.5ms" rather than "0.0015s".
+		var prec int
+		w--
+		buf[w] = 's'
+		w--
+		switch {
+		case u == 0:
+			b.bs = append(b.bs, "0s"...)
+			return
+		case u < uint64(time.Microsecond):
+			buf[w] = 'n'
+		case u < uint64(time.Millisecond):
+			prec = 3
+			w--
+			copy(buf[w:], "µ")
+		default:
+			prec = 6
+			buf[w] = 'm'
+		}
+		w, u = fmtFrac(buf[:w], u, prec)
+		w = fmtInt(buf[:w], u)
+	} else {
+		w--
+		buf[w] = 's'
+		w, u = fmtFrac(buf[:w], u, 9)
+		w = fmtInt(buf[:w], u%60)
+		if u /= 60; u > 0 {
+			w--
+			buf[w] = 'm'
+			w = fmtInt(buf[:w], u%60)
+			if u /= 60; u > 0 {
+				w--
+				buf[w] = 'h'
+				w = fmtInt(buf[:w], u)
+			}
+		}
+	}
+	if d < 0 {
+		w--
+		buf[w] = '-'
+	}
+	b.bs = append(b.bs, buf[w:]...)
+}
+
+// fmtFrac formats the fraction of v/10**prec at the end of buf, omitting
+// trailing zeros and the dot if the fraction is zero. It returns the
+// index where the output begins and v/10**prec.
+func fmtFrac(buf []byte, v uint64, prec int) (int, uint64) {
+	w := len(buf)
+	print := false
+	for i := 0; i < prec; i++ {
+		digit := v % 10
+		print = print || digit != 0
+		if print {
+			w--
+			buf[w] = byte(digit) + '0'
+		}
+		v /= 10
+	}
+	if print {
+		w--
+		buf[w] = '.'
+	}
+	return w, v
+}
+
+// fmtInt formats v at the end of buf and returns the index where the
+// output begins.
+func fmtInt(buf []byte, v uint64) int {
+	w := len(buf)
+	if v == 0 {
+		w--
+		buf[w] = '0'
+		return w
+	}
+	for ; v > 0; v /= 10 {
+		w--
+		buf[w] = byte(v%10) + '0'
+	}
+	return w
+}
+
+// AppendUnix appends t as seconds since the Unix epoch.
+func (b *Buffer) AppendUnix(t time.Time) {
+	b.checkUse()
+	b.bs = strconv.AppendInt(b.bs, t.Unix(), 10)
+}
+
+// AppendUnixMilli appends t as milliseconds since the Unix epoch.
+func (b *Buffer) AppendUnixMilli(t time.Time) {
+	b.checkUse()
+	b.bs = strconv.AppendInt(b.bs, t.UnixMilli(), 10)
+}
+
+// AppendUnixMicro appends t as microseconds since the Unix epoch.
+func (b *Buffer) AppendUnixMicro(t time.Time) {
+	b.checkUse()
+	b.bs = strconv.AppendInt(b.bs, t.UnixMicro(), 10)
+}
+
+// AppendUnixNano appends t as nanoseconds since the Unix epoch.
+func (b *Buffer) AppendUnixNano(t time.Time) {
+	b.checkUse()
+	b.bs = strconv.AppendInt(b.bs, t.UnixNano(), 10)
+}
+
+// Len returns the number of unread bytes in the buffer.
 func (b *Buffer) Len() int {
-	return len(b.bs)
+	b.checkUse()
+	return len(b.bs) - b.off
 }
 
 // Cap returns the capacity of the underlying byte slice.
 func (b *Buffer) Cap() int {
+	b.checkUse()
 	return cap(b.bs)
 }
 
-// Bytes returns a mutable reference to the underlying byte slice.
+// Bytes returns a mutable reference to the unread portion of the
+// underlying byte slice.
 func (b *Buffer) Bytes() []byte {
-	return b.bs
+	b.checkUse()
+	return b.bs[b.off:]
 }
 
-// String returns a string copy of the underlying byte slice.
+// String returns a copy of the unread portion of the buffer as a string.
 func (b *Buffer) String() string {
-	//unsafe.String(unsafe.SliceData(b.buf), len(b.buf)) go 1.20
-	return unsafe.String(unsafe.SliceData(b.bs), len(b.bs))
+	b.checkUse()
+	return string(b.bs[b.off:])
+}
+
+// UnsafeString returns the unread portion of the buffer as a string without
+// copying it. The string shares the buffer's memory, so it changes if the
+// buffer is written to, reset or reused after Free; it must not be retained
+// beyond the next such call.
+func (b *Buffer) UnsafeString() string {
+	b.checkUse()
+	bs := b.bs[b.off:]
+	return unsafe.String(unsafe.SliceData(bs), len(bs))
 }
 
 // Reset resets the underlying byte slice. Subsequent writes re-use the slice's
 // backing array.
 func (b *Buffer) Reset() {
+	b.checkUse()
 	b.bs = b.bs[:0]
+	b.off = 0
+	b.lastRead = false
+	b.depth = 0
 }
 
 // Write implements io.Writer.
 func (b *Buffer) Write(bs []byte) (int, error) {
+	b.checkUse()
 	b.bs = append(b.bs, bs...)
 	return len(bs), nil
 }
@@ -106,11 +299,16 @@ func (b *Buffer) WriteString(s string) (int, error) {
 	return len(s), nil
 }
 
+// WriteInterface appends value in a form suited to its type: Marshaler
+// and ArrayMarshaler values as JSON, other values that need it through
+// encoding/json. If encoding fails, the error message is written in its
+// place: as an "error" member of a Marshaler's object, as the last
+// element of an ArrayMarshaler's array, or as a JSON string.
 func (b *Buffer) WriteInterface(value any) {
 	switch fValue := value.(type) {
 	case string:
 		if needsQuote(fValue) {
-			b.WriteString(strconv.Quote(fValue))
+			b.AppendJSONString(fValue)
 		} else {
 			b.WriteString(fValue)
 		}
@@ -141,6 +339,20 @@ func (b *Buffer) WriteInterface(value any) {
 		b.AppendFloat(float64(fValue), 64)
 	case bool:
 		b.AppendBool(fValue)
+	case Marshaler:
+		e := NewJSONEncoder(b)
+		b.AppendObjectBegin()
+		if err := fValue.MarshalObject(e); err != nil {
+			e.AddString("error", err.Error())
+		}
+		b.AppendObjectEnd()
+	case ArrayMarshaler:
+		e := NewJSONEncoder(b)
+		b.AppendArrayBegin()
+		if err := fValue.MarshalArray(e); err != nil {
+			e.AppendString(err.Error())
+		}
+		b.AppendArrayEnd()
 	case error:
 		b.WriteString(fValue.Error())
 	case []byte:
@@ -154,7 +366,7 @@ func (b *Buffer) WriteInterface(value any) {
 	default:
 		js, err := json.Marshal(fValue)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+			b.AppendJSONString(err.Error())
 		} else {
 			b.Write(js)
 		}
@@ -163,7 +375,9 @@ func (b *Buffer) WriteInterface(value any) {
 
 // TrimNewline trims any final "\n" byte from the end of the buffer.
 func (b *Buffer) TrimNewline() {
-	if i := len(b.bs) - 1; i >= 0 {
+	b.checkUse()
+	// Bytes already read are left alone.
+	if i := len(b.bs) - 1; i >= b.off {
 		if b.bs[i] == '\n' {
 			b.bs = b.bs[:i]
 		}
@@ -172,7 +386,8 @@ func (b *Buffer) TrimNewline() {
 
 // WriteNewLine writes a new line to the buffer if it's needed.
 func (b *Buffer) WriteNewLine() {
-	if length := b.Len(); length > 0 && b.bs[length-1] != '\n' {
+	b.checkUse()
+	if length := len(b.bs); length > 0 && b.bs[length-1] != '\n' {
 		b.WriteByte('\n') // nolint:errcheck
 	}
 }
@@ -26,6 +26,20 @@ func TestBufferWrites(t *testing.T) {
 		{"AppendFloat64", func() { buf.AppendFloat(3.14, 64) }, "3.14"},
 		// Intentionally introduce some floating-point error.
 		{"AppendFloat32", func() { buf.AppendFloat(float64(float32(3.14)), 32) }, "3.14"},
+		{"AppendFloatFmtExp", func() { buf.AppendFloatFmt(1234.5678, 'e', 3, 64) }, "1.235e+03"},
+		{"AppendFloatFmtFixed", func() { buf.AppendFloatFmt(3.14159, 'f', 2, 64) }, "3.14"},
+		{"AppendFloatFmtGeneral", func() { buf.AppendFloatFmt(float64(float32(0.1)), 'g', -1, 32) }, "0.1"},
+		{"AppendIntHex", func() { buf.AppendIntHex(-255) }, "-ff"},
+		{"AppendUintHex", func() { buf.AppendUintHex(0xdeadbeef) }, "deadbeef"},
+		{"AppendIntBinary", func() { buf.AppendIntBinary(-5) }, "-101"},
+		{"AppendUintBinary", func() { buf.AppendUintBinary(10) }, "1010"},
+		{"AppendDurationMillis", func() { buf.AppendDuration(1500*time.Microsecond, time.Millisecond) }, "1.5"},
+		{"AppendDurationSeconds", func() { buf.AppendDuration(3*time.Second, time.Second) }, "3"},
+		{"AppendDurationFraction", func() { buf.AppendDuration(-250*time.Millisecond, time.Second) }, "-0.25"},
+		{"AppendUnix", func() { buf.AppendUnix(time.Unix(1700000000, 123456789)) }, "1700000000"},
+		{"AppendUnixMilli", func() { buf.AppendUnixMilli(time.Unix(1700000000, 123456789)) }, "1700000000123"},
+		{"AppendUnixMicro", func() { buf.AppendUnixMicro(time.Unix(1700000000, 123456789)) }, "1700000000123456"},
+		{"AppendUnixNano", func() { buf.AppendUnixNano(time.Unix(1700000000, 123456789)) }, "1700000000123456789"},
 		{"AppendWrite", func() { buf.Write([]byte("foo")) }, "foo"},
 		{"AppendTime", func() { buf.AppendTime(time.Date(2000, 1, 2, 3, 4, 5, 6, time.UTC), time.RFC3339) }, "2000-01-02T03:04:05Z"},
 		{"WriteByte", func() { buf.WriteByte('v') }, "v"},
@@ -45,6 +59,27 @@ func TestBufferWrites(t *testing.T) {
 	}
 }
 
+func TestAppendDurationUnit(t *testing.T) {
+	buf := NewPool().Get()
+	defer buf.Free()
+	assert.PanicsWithValue(t, "buffer: non-positive duration unit", func() { buf.AppendDuration(time.Second, 0) })
+	assert.Panics(t, func() { buf.AppendDuration(time.Second, -time.Second) })
+	assert.Zero(t, buf.Len(), "Expected nothing appended.")
+}
+
+func TestAppendDurationString(t *testing.T) {
+	buf := NewPool().Get()
+	defer buf.Free()
+	for _, d := range []time.Duration{
+		0, 1, -1, 999, time.Microsecond, 1500 * time.Nanosecond, time.Millisecond + 1,
+		time.Second, -time.Second - 1, 61 * time.Minute, 1<<63 - 1, -1 << 63,
+	} {
+		buf.Reset()
+		buf.appendDurationString(d)
+		assert.Equal(t, d.String(), buf.String(), "Unexpected formatting of %d.", int64(d))
+	}
+}
+
 func BenchmarkBuffers(b *testing.B) {
 	// Because we use the strconv.AppendFoo functions so liberally, we can't
 	// use the standard library's bytes.Buffer anyways (without incurring a
@@ -73,3 +108,14 @@ func BenchmarkBuffers(b *testing.B) {
 		}
 	})
 }
+
+func TestBufferStringCopy(t *testing.T) {
+	buf := NewPool().Get()
+	buf.AppendString("hello")
+	s, u := buf.String(), buf.UnsafeString()
+
+	buf.Reset()
+	buf.AppendString("world")
+	assert.Equal(t, "hello", s, "Expected String to return a copy")
+	assert.Equal(t, "world", u, "Expected UnsafeString to share the buffer's memory")
+}
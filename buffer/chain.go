@@ -0,0 +1,143 @@
+package buffer
+
+import (
+	"io"
+	"net"
+)
+
+// A Chain is a sequence of bytes stored in fixed-size Buffers drawn from a
+// Pool. Appending never copies what was written before, which makes it
+// suited to assembling large messages. Like a Buffer, a Chain must be
+// freed when it is no longer needed.
+type Chain struct {
+	pool Pool
+	size int
+	segs []*Buffer
+	n    int
+	iov  net.Buffers // scratch space for WriteTo
+}
+
+// NewChain returns an empty Chain whose segments come from p and hold at
+// least size bytes each. If size is not positive, segments are the size of
+// p's smallest class.
+func NewChain(p Pool, size int) *Chain {
+	if size <= 0 {
+		size = p.c.min
+	}
+	return &Chain{pool: p, size: size}
+}
+
+// Len returns the number of bytes in the chain.
+func (c *Chain) Len() int {
+	return c.n
+}
+
+// AppendByte writes a single byte to the chain.
+func (c *Chain) AppendByte(v byte) {
+	seg := c.tail()
+	seg.bs = append(seg.bs, v)
+	c.n++
+}
+
+// AppendString writes a string to the chain.
+func (c *Chain) AppendString(s string) {
+	c.n += len(s)
+	for len(s) > 0 {
+		seg := c.tail()
+		n := copy(seg.bs[len(seg.bs):cap(seg.bs)], s)
+		seg.bs = seg.bs[:len(seg.bs)+n]
+		s = s[n:]
+	}
+}
+
+// Write implements io.Writer.
+func (c *Chain) Write(bs []byte) (int, error) {
+	c.n += len(bs)
+	for rest := bs; len(rest) > 0; {
+		seg := c.tail()
+		n := copy(seg.bs[len(seg.bs):cap(seg.bs)], rest)
+		seg.bs = seg.bs[:len(seg.bs)+n]
+		rest = rest[n:]
+	}
+	return len(bs), nil
+}
+
+// WriteByte writes a single byte to the chain.
+//
+// Error returned is always nil, function signature is compatible
+// with bytes.Buffer and bufio.Writer
+func (c *Chain) WriteByte(v byte) error {
+	c.AppendByte(v)
+	return nil
+}
+
+// WriteString writes a string to the chain.
+//
+// Error returned is always nil, function signature is compatible
+// with bytes.Buffer and bufio.Writer
+func (c *Chain) WriteString(s string) (int, error) {
+	c.AppendString(s)
+	return len(s), nil
+}
+
+// WriteTo writes the contents of the chain to w, using a single writev
+// system call when w is a net.Conn that supports it. The chain itself is
+// left unchanged.
+func (c *Chain) WriteTo(w io.Writer) (int64, error) {
+	c.iov = c.iov[:0]
+	for _, seg := range c.segs {
+		c.iov = append(c.iov, seg.bs)
+	}
+	iov := c.iov
+	return iov.WriteTo(w)
+}
+
+// Slice returns the bytes in [start, end) as views of the segments,
+// without copying. The views are valid until the chain is freed. Slice
+// panics if the range is out of bounds.
+func (c *Chain) Slice(start, end int) net.Buffers {
+	if start < 0 || end < start || end > c.n {
+		panic("buffer: slice bounds out of range")
+	}
+	var out net.Buffers
+	for _, seg := range c.segs {
+		if end <= 0 {
+			break
+		}
+		n := len(seg.bs)
+		if start < n {
+			out = append(out, seg.bs[start:minInt(end, n)])
+		}
+		start = maxInt(start-n, 0)
+		end -= n
+	}
+	return out
+}
+
+// Free returns all segments to their Pool and empties the chain, which
+// may then be reused.
+//
+// Callers must not retain views returned by Slice after calling Free.
+func (c *Chain) Free() {
+	for i, seg := range c.segs {
+		seg.Free()
+		c.segs[i] = nil
+	}
+	c.segs = c.segs[:0]
+	for i := range c.iov {
+		c.iov[i] = nil
+	}
+	c.n = 0
+}
+
+// tail returns the last segment, adding one if it is full.
+func (c *Chain) tail() *Buffer {
+	if n := len(c.segs); n > 0 {
+		if seg := c.segs[n-1]; len(seg.bs) < cap(seg.bs) {
+			return seg
+		}
+	}
+	seg := c.pool.GetSize(c.size)
+	c.segs = append(c.segs, seg)
+	return seg
+}
@@ -0,0 +1,60 @@
+package buffer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	p := NewPoolClasses(16, 1024)
+	c := NewChain(p, 16)
+
+	var want strings.Builder
+	for i := 0; i < 10; i++ {
+		c.AppendString("hello ")
+		c.Write([]byte("world"))
+		c.AppendByte('\n')
+		want.WriteString("hello world\n")
+	}
+	assert.Equal(t, want.Len(), c.Len())
+	assert.Len(t, c.segs, (want.Len()+15)/16, "Expected segments to be filled before adding another")
+	for _, seg := range c.segs {
+		assert.Equal(t, 16, seg.Cap(), "Expected segments to keep their size")
+	}
+
+	var out bytes.Buffer
+	n, err := c.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Equal(t, int64(want.Len()), n)
+	assert.Equal(t, want.String(), out.String())
+
+	// WriteTo leaves the chain intact.
+	out.Reset()
+	_, err = c.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Equal(t, want.String(), out.String())
+
+	for _, r := range [][2]int{{0, 0}, {0, 5}, {10, 40}, {16, 32}, {3, c.Len()}, {c.Len(), c.Len()}} {
+		got := c.Slice(r[0], r[1])
+		assert.Equal(t, want.String()[r[0]:r[1]], string(bytes.Join(got, nil)), "Unexpected Slice(%d, %d)", r[0], r[1])
+	}
+	view := c.Slice(0, 5)
+	assert.Same(t, &c.segs[0].bs[0], &view[0][0], "Expected Slice not to copy")
+	assert.Panics(t, func() { c.Slice(0, c.Len()+1) })
+	assert.Panics(t, func() { c.Slice(5, 4) })
+
+	segs := int64(len(c.segs))
+	c.Free()
+	assert.Zero(t, c.Len())
+	assert.Equal(t, segs, p.Stats().Puts, "Expected all segments back in the pool")
+
+	c.AppendString("reused")
+	out.Reset()
+	c.WriteTo(&out)
+	assert.Equal(t, "reused", out.String())
+	c.Free()
+}
@@ -0,0 +1,125 @@
+package buffer
+
+import "time"
+
+// ConsoleTimeLayout is the layout ConsoleEncoder writes times with.
+const ConsoleTimeLayout = "2006-01-02T15:04:05.000Z0700"
+
+// ConsoleEncoder encodes objects for people rather than parsers: fields are
+// written as key=value separated by spaces, without quoting, nested
+// objects as key={a=1 b=2} and arrays as key=[1, 2].
+type ConsoleEncoder struct {
+	b *Buffer
+}
+
+var _ ObjectEncoder = ConsoleEncoder{}
+
+// NewConsoleEncoder returns a ConsoleEncoder writing to b.
+func NewConsoleEncoder(b *Buffer) ConsoleEncoder {
+	return ConsoleEncoder{b: b}
+}
+
+func (e ConsoleEncoder) key(key string) {
+	if n := len(e.b.bs); n > 0 {
+		switch e.b.bs[n-1] {
+		case ' ', '\t', '\n', '{':
+		default:
+			e.b.AppendByte(' ')
+		}
+	}
+	e.b.AppendString(key)
+	e.b.AppendByte('=')
+}
+
+func (e ConsoleEncoder) AddString(key, value string) {
+	e.key(key)
+	e.b.AppendString(value)
+}
+
+func (e ConsoleEncoder) AddInt64(key string, value int64) {
+	e.key(key)
+	e.b.AppendInt(value)
+}
+
+func (e ConsoleEncoder) AddUint64(key string, value uint64) {
+	e.key(key)
+	e.b.AppendUint(value)
+}
+
+func (e ConsoleEncoder) AddFloat64(key string, value float64) {
+	e.key(key)
+	e.b.AppendFloat(value, 64)
+}
+
+func (e ConsoleEncoder) AddBool(key string, value bool) {
+	e.key(key)
+	e.b.AppendBool(value)
+}
+
+func (e ConsoleEncoder) AddTime(key string, value time.Time) {
+	e.key(key)
+	e.b.AppendTime(value, ConsoleTimeLayout)
+}
+
+func (e ConsoleEncoder) AddDuration(key string, value time.Duration) {
+	e.key(key)
+	e.b.appendDurationString(value)
+}
+
+func (e ConsoleEncoder) AddObject(key string, value Marshaler) error {
+	e.key(key)
+	return e.appendObject(value)
+}
+
+func (e ConsoleEncoder) AddArray(key string, value ArrayMarshaler) error {
+	e.key(key)
+	return e.appendArray(value)
+}
+
+func (e ConsoleEncoder) appendObject(value Marshaler) error {
+	e.b.AppendByte('{')
+	err := value.MarshalObject(e)
+	e.b.AppendByte('}')
+	return err
+}
+
+func (e ConsoleEncoder) appendArray(value ArrayMarshaler) error {
+	e.b.AppendByte('[')
+	err := value.MarshalArray(&consoleArray{e: e})
+	e.b.AppendByte(']')
+	return err
+}
+
+// consoleArray separates elements with commas.
+type consoleArray struct {
+	e       ConsoleEncoder
+	started bool
+}
+
+func (a *consoleArray) sep() *Buffer {
+	if a.started {
+		a.e.b.AppendString(", ")
+	}
+	a.started = true
+	return a.e.b
+}
+
+func (a *consoleArray) AppendString(v string)   { a.sep().AppendString(v) }
+func (a *consoleArray) AppendInt64(v int64)     { a.sep().AppendInt(v) }
+func (a *consoleArray) AppendUint64(v uint64)   { a.sep().AppendUint(v) }
+func (a *consoleArray) AppendFloat64(v float64) { a.sep().AppendFloat(v, 64) }
+func (a *consoleArray) AppendBool(v bool)       { a.sep().AppendBool(v) }
+func (a *consoleArray) AppendTime(v time.Time)  { a.sep().AppendTime(v, ConsoleTimeLayout) }
+func (a *consoleArray) AppendDuration(v time.Duration) {
+	a.sep().appendDurationString(v)
+}
+
+func (a *consoleArray) AppendObject(v Marshaler) error {
+	a.sep()
+	return a.e.appendObject(v)
+}
+
+func (a *consoleArray) AppendArray(v ArrayMarshaler) error {
+	a.sep()
+	return a.e.appendArray(v)
+}
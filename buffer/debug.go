@@ -0,0 +1,115 @@
+//go:build bufferdebug
+
+package buffer
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// With the bufferdebug build tag, every Buffer remembers the stack of the
+// Get that handed it out. A Buffer collected by the garbage collector
+// without being freed, and a Buffer freed twice, are reported through
+// debugReport.
+//
+// Free also poisons the backing array and retires the Buffer: any later
+// use of it panics, and the pool receives a new Buffer wrapping the same
+// array instead. Strings obtained from UnsafeString before Free show the
+// poison.
+
+// _poison fills the backing array of freed buffers.
+const _poison = 0xdd
+
+// debugEnabled reports whether the bufferdebug build tag is set.
+const debugEnabled = true
+
+type debugState struct {
+	mu      sync.Mutex
+	live    bool
+	retired bool
+	stack   [32]uintptr
+	depth   int
+}
+
+var (
+	reportMu sync.Mutex
+	// debugReport is called with a description of each misuse detected.
+	// It writes to standard error by default.
+	debugReport = func(msg string) {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+)
+
+func report(msg string) {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	debugReport(msg)
+}
+
+func debugAlloc(b *Buffer) {
+	runtime.SetFinalizer(b, func(b *Buffer) {
+		b.debug.mu.Lock()
+		defer b.debug.mu.Unlock()
+		if b.debug.live {
+			report("buffer: Buffer garbage-collected without Free; got at:\n" + b.debug.trace())
+		}
+	})
+}
+
+func debugGet(b *Buffer) {
+	b.debug.mu.Lock()
+	defer b.debug.mu.Unlock()
+	b.debug.live = true
+	b.debug.depth = runtime.Callers(2, b.debug.stack[:])
+}
+
+// debugPut retires b and returns the Buffer to put in its place into the
+// pool, or nil if b must not go back.
+func debugPut(b *Buffer) *Buffer {
+	b.debug.mu.Lock()
+	defer b.debug.mu.Unlock()
+	if !b.debug.live {
+		var pcs [32]uintptr
+		n := runtime.Callers(2, pcs[:])
+		report("buffer: Buffer freed twice; got at:\n" + b.debug.trace() +
+			"freed again at:\n" + formatStack(pcs[:n]))
+		return nil
+	}
+	b.debug.live = false
+	b.debug.retired = true
+
+	bs := b.bs[:cap(b.bs)]
+	for i := range bs {
+		bs[i] = _poison
+	}
+	next := &Buffer{bs: b.bs[:0]}
+	debugAlloc(next)
+	b.bs = nil
+	return next
+}
+
+// checkUse panics if b has been freed.
+func (b *Buffer) checkUse() {
+	if b.debug.retired {
+		panic("buffer: use of Buffer after Free")
+	}
+}
+
+func (d *debugState) trace() string {
+	return formatStack(d.stack[:d.depth])
+}
+
+func formatStack(pcs []uintptr) string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&sb, "\t%s\n\t\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			return sb.String()
+		}
+	}
+}
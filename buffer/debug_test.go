@@ -0,0 +1,95 @@
+//go:build bufferdebug
+
+package buffer
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func captureReports(t *testing.T) <-chan string {
+	ch := make(chan string, 16)
+	reportMu.Lock()
+	orig := debugReport
+	debugReport = func(msg string) {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+	reportMu.Unlock()
+	t.Cleanup(func() {
+		reportMu.Lock()
+		debugReport = orig
+		reportMu.Unlock()
+	})
+	return ch
+}
+
+func leakBuffer(p Pool) {
+	p.Get().AppendString("leaked")
+}
+
+func TestDebugLeak(t *testing.T) {
+	reports := captureReports(t)
+	leakBuffer(NewPool())
+
+	deadline := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case msg := <-reports:
+			// Buffers leaked by other tests may be reported first.
+			if strings.Contains(msg, "leakBuffer") {
+				assert.Contains(t, msg, "without Free")
+				return
+			}
+		case <-deadline:
+			t.Fatal("leaked buffer not reported")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestDebugDoubleFree(t *testing.T) {
+	reports := captureReports(t)
+	p := NewPool()
+
+	buf := p.Get()
+	buf.Free()
+	buf.Free()
+
+	select {
+	case msg := <-reports:
+		assert.Contains(t, msg, "freed twice")
+		assert.Contains(t, msg, "TestDebugDoubleFree")
+	default:
+		t.Fatal("double free not reported")
+	}
+	require.Equal(t, int64(1), p.Stats().Puts, "Expected second Free to be ignored")
+}
+
+func TestDebugUseAfterFree(t *testing.T) {
+	p := NewPool()
+	buf := p.Get()
+	buf.AppendString("secret")
+	s, u := buf.String(), buf.UnsafeString()
+	buf.Free()
+
+	assert.Equal(t, "secret", s)
+	assert.Equal(t, strings.Repeat("\xdd", 6), u, "Expected backing array to be poisoned")
+	assert.PanicsWithValue(t, "buffer: use of Buffer after Free", func() { buf.AppendString("x") })
+	assert.Panics(t, func() { buf.Len() })
+	assert.Panics(t, func() { buf.WriteInterface(1) })
+
+	next := p.Get()
+	assert.NotSame(t, buf, next, "Expected freed Buffer to be retired")
+	next.AppendString("ok")
+	assert.Equal(t, "ok", next.String())
+	next.Free()
+}
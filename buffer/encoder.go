@@ -0,0 +1,185 @@
+package buffer
+
+import (
+	"math"
+	"time"
+)
+
+// An ObjectEncoder adds the fields of an object to a Buffer in some output
+// format.
+type ObjectEncoder interface {
+	AddString(key, value string)
+	AddInt64(key string, value int64)
+	AddUint64(key string, value uint64)
+	AddFloat64(key string, value float64)
+	AddBool(key string, value bool)
+	AddTime(key string, value time.Time)
+	AddDuration(key string, value time.Duration)
+	AddObject(key string, value Marshaler) error
+	AddArray(key string, value ArrayMarshaler) error
+}
+
+// An ArrayEncoder adds the elements of an array to a Buffer in some output
+// format.
+type ArrayEncoder interface {
+	AppendString(value string)
+	AppendInt64(value int64)
+	AppendUint64(value uint64)
+	AppendFloat64(value float64)
+	AppendBool(value bool)
+	AppendTime(value time.Time)
+	AppendDuration(value time.Duration)
+	AppendObject(value Marshaler) error
+	AppendArray(value ArrayMarshaler) error
+}
+
+// Marshaler is implemented by types that encode themselves as an object,
+// so encoders need no reflection to handle them.
+type Marshaler interface {
+	MarshalObject(ObjectEncoder) error
+}
+
+// ArrayMarshaler is implemented by types that encode themselves as an
+// array.
+type ArrayMarshaler interface {
+	MarshalArray(ArrayEncoder) error
+}
+
+// JSONEncoder encodes objects and arrays as JSON. It only writes the
+// members and elements; callers open and close the outermost object or
+// array with AppendObjectBegin and AppendObjectEnd (or the array
+// equivalents) on the Buffer. Times are written as RFC 3339 strings and
+// durations as strings like "1.5s".
+type JSONEncoder struct {
+	b *Buffer
+}
+
+var (
+	_ ObjectEncoder = JSONEncoder{}
+	_ ArrayEncoder  = JSONEncoder{}
+)
+
+// NewJSONEncoder returns a JSONEncoder writing to b.
+func NewJSONEncoder(b *Buffer) JSONEncoder {
+	return JSONEncoder{b: b}
+}
+
+func (e JSONEncoder) AddString(key, value string) {
+	e.b.AppendJSONKey(key)
+	e.b.AppendJSONString(value)
+}
+
+func (e JSONEncoder) AddInt64(key string, value int64) {
+	e.b.AppendJSONKey(key)
+	e.b.AppendInt(value)
+}
+
+func (e JSONEncoder) AddUint64(key string, value uint64) {
+	e.b.AppendJSONKey(key)
+	e.b.AppendUint(value)
+}
+
+func (e JSONEncoder) AddFloat64(key string, value float64) {
+	e.b.AppendJSONKey(key)
+	e.appendFloat(value)
+}
+
+func (e JSONEncoder) AddBool(key string, value bool) {
+	e.b.AppendJSONKey(key)
+	e.b.AppendBool(value)
+}
+
+func (e JSONEncoder) AddTime(key string, value time.Time) {
+	e.b.AppendJSONKey(key)
+	e.appendTime(value)
+}
+
+func (e JSONEncoder) AddDuration(key string, value time.Duration) {
+	e.b.AppendJSONKey(key)
+	e.appendDuration(value)
+}
+
+func (e JSONEncoder) AddObject(key string, value Marshaler) error {
+	e.b.AppendJSONKey(key)
+	return e.AppendObject(value)
+}
+
+func (e JSONEncoder) AddArray(key string, value ArrayMarshaler) error {
+	e.b.AppendJSONKey(key)
+	return e.AppendArray(value)
+}
+
+func (e JSONEncoder) AppendString(value string) {
+	e.b.AppendElementSeparator()
+	e.b.AppendJSONString(value)
+}
+
+func (e JSONEncoder) AppendInt64(value int64) {
+	e.b.AppendElementSeparator()
+	e.b.AppendInt(value)
+}
+
+func (e JSONEncoder) AppendUint64(value uint64) {
+	e.b.AppendElementSeparator()
+	e.b.AppendUint(value)
+}
+
+func (e JSONEncoder) AppendFloat64(value float64) {
+	e.b.AppendElementSeparator()
+	e.appendFloat(value)
+}
+
+func (e JSONEncoder) AppendBool(value bool) {
+	e.b.AppendElementSeparator()
+	e.b.AppendBool(value)
+}
+
+func (e JSONEncoder) AppendTime(value time.Time) {
+	e.b.AppendElementSeparator()
+	e.appendTime(value)
+}
+
+func (e JSONEncoder) AppendDuration(value time.Duration) {
+	e.b.AppendElementSeparator()
+	e.appendDuration(value)
+}
+
+func (e JSONEncoder) AppendObject(value Marshaler) error {
+	e.b.AppendObjectBegin()
+	err := value.MarshalObject(e)
+	e.b.AppendObjectEnd()
+	return err
+}
+
+func (e JSONEncoder) AppendArray(value ArrayMarshaler) error {
+	e.b.AppendArrayBegin()
+	err := value.MarshalArray(e)
+	e.b.AppendArrayEnd()
+	return err
+}
+
+// appendFloat quotes NaN and infinities, which JSON numbers cannot hold.
+func (e JSONEncoder) appendFloat(f float64) {
+	switch {
+	case math.IsNaN(f):
+		e.b.AppendString(`"NaN"`)
+	case math.IsInf(f, 1):
+		e.b.AppendString(`"+Inf"`)
+	case math.IsInf(f, -1):
+		e.b.AppendString(`"-Inf"`)
+	default:
+		e.b.AppendFloat(f, 64)
+	}
+}
+
+func (e JSONEncoder) appendTime(t time.Time) {
+	e.b.AppendByte('"')
+	e.b.AppendTime(t, time.RFC3339Nano)
+	e.b.AppendByte('"')
+}
+
+func (e JSONEncoder) appendDuration(d time.Duration) {
+	e.b.AppendByte('"')
+	e.b.appendDurationString(d)
+	e.b.AppendByte('"')
+}
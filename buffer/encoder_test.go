@@ -0,0 +1,146 @@
+package buffer
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAddr struct {
+	Host string
+	Port int64
+}
+
+func (a testAddr) MarshalObject(e ObjectEncoder) error {
+	e.AddString("host", a.Host)
+	e.AddInt64("port", a.Port)
+	return nil
+}
+
+type testAddrs []testAddr
+
+func (as testAddrs) MarshalArray(e ArrayEncoder) error {
+	for _, a := range as {
+		if err := e.AppendObject(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type testInts []int64
+
+func (is testInts) MarshalArray(e ArrayEncoder) error {
+	for _, i := range is {
+		e.AppendInt64(i)
+	}
+	return nil
+}
+
+type testEvent struct{}
+
+var testTime = time.Date(2000, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+func (testEvent) MarshalObject(e ObjectEncoder) error {
+	e.AddString("msg", "hello world")
+	e.AddUint64("n", 42)
+	e.AddFloat64("f", 1.5)
+	e.AddBool("ok", true)
+	e.AddTime("ts", testTime)
+	e.AddDuration("took", 1500*time.Millisecond)
+	if err := e.AddObject("addr", testAddr{"localhost", 80}); err != nil {
+		return err
+	}
+	if err := e.AddArray("ids", testInts{1, 2}); err != nil {
+		return err
+	}
+	return e.AddArray("peers", testAddrs{{"a", 1}, {"b", 2}})
+}
+
+type failing struct{}
+
+func (failing) MarshalObject(ObjectEncoder) error { return errors.New("boom") }
+
+type failingArray struct{}
+
+func (failingArray) MarshalArray(e ArrayEncoder) error {
+	e.AppendInt64(1)
+	return errors.New("boom")
+}
+
+func TestJSONEncoder(t *testing.T) {
+	buf := NewPool().Get()
+	e := NewJSONEncoder(buf)
+	buf.AppendObjectBegin()
+	require.NoError(t, testEvent{}.MarshalObject(e))
+	e.AddFloat64("nan", math.NaN())
+	buf.AppendObjectEnd()
+
+	want := `{"msg":"hello world","n":42,"f":1.5,"ok":true,"ts":"2000-01-02T03:04:05.006Z",` +
+		`"took":"1.5s","addr":{"host":"localhost","port":80},"ids":[1,2],` +
+		`"peers":[{"host":"a","port":1},{"host":"b","port":2}],"nan":"NaN"}`
+	assert.Equal(t, want, buf.String())
+	assert.True(t, json.Valid(buf.Bytes()))
+
+	assert.EqualError(t, e.AddObject("bad", failing{}), "boom")
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+	buf := NewPool().Get()
+	require.NoError(t, testEvent{}.MarshalObject(NewLogfmtEncoder(buf)))
+	want := `msg="hello world" n=42 f=1.5 ok=true ts=2000-01-02T03:04:05.006Z took=1.5s ` +
+		`addr.host=localhost addr.port=80 ids.0=1 ids.1=2 ` +
+		`peers.0.host=a peers.0.port=1 peers.1.host=b peers.1.port=2`
+	assert.Equal(t, want, buf.String())
+}
+
+func TestConsoleEncoder(t *testing.T) {
+	buf := NewPool().Get()
+	buf.AppendString("INFO\t")
+	require.NoError(t, testEvent{}.MarshalObject(NewConsoleEncoder(buf)))
+	want := "INFO\tmsg=hello world n=42 f=1.5 ok=true ts=2000-01-02T03:04:05.006Z took=1.5s " +
+		"addr={host=localhost port=80} ids=[1, 2] peers=[{host=a port=1}, {host=b port=2}]"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestMarshalerIntegration(t *testing.T) {
+	buf := NewPool().Get()
+	buf.WriteInterface(testAddr{"localhost", 80})
+	assert.Equal(t, `{"host":"localhost","port":80}`, buf.String())
+
+	buf.Reset()
+	buf.WriteInterface(testAddrs{{"a", 1}, {"b", 2}})
+	assert.Equal(t, `[{"host":"a","port":1},{"host":"b","port":2}]`, buf.String())
+
+	// Errors end up in the output rather than on stderr.
+	buf.Reset()
+	buf.WriteInterface(failing{})
+	buf.WriteInterface(failingArray{})
+	buf.WriteInterface(func() {})
+	assert.Equal(t, `{"error":"boom"}[1,"boom"]"json: unsupported type: func()"`, buf.String())
+
+	buf.Reset()
+	buf.AppendLogfmt("addr", testAddr{"localhost", 80})
+	buf.AppendLogfmt("ids", testInts{7})
+	buf.AppendLogfmt("bad", failing{})
+	assert.Equal(t, "addr.host=localhost addr.port=80 ids.0=7 badError=boom", buf.String())
+}
+
+func TestJSONEncoderAllocs(t *testing.T) {
+	buf := NewPool().Get()
+	var m Marshaler = testAddr{"localhost", 80}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		e := NewJSONEncoder(buf)
+		buf.AppendObjectBegin()
+		e.AddString("msg", "hello")
+		e.AddObject("addr", m)
+		buf.AppendObjectEnd()
+	})
+	assert.Zero(t, allocs, "Expected no allocations")
+}
@@ -0,0 +1,83 @@
+package buffer
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// AppendBase64 appends src encoded with enc, such as base64.StdEncoding,
+// base64.URLEncoding or their Raw (unpadded) variants.
+func (b *Buffer) AppendBase64(enc *base64.Encoding, src []byte) {
+	b.checkUse()
+	n := enc.EncodedLen(len(src))
+	b.Grow(n)
+	m := len(b.bs)
+	b.bs = b.bs[:m+n]
+	enc.Encode(b.bs[m:], src)
+}
+
+// AppendHex appends src in lowercase hexadecimal.
+func (b *Buffer) AppendHex(src []byte) {
+	b.checkUse()
+	n := hex.EncodedLen(len(src))
+	b.Grow(n)
+	m := len(b.bs)
+	b.bs = b.bs[:m+n]
+	hex.Encode(b.bs[m:], src)
+}
+
+// AppendQuotedHex appends src in lowercase hexadecimal between double
+// quotes, ready for use as a JSON string.
+func (b *Buffer) AppendQuotedHex(src []byte) {
+	b.AppendByte('"')
+	b.AppendHex(src)
+	b.AppendByte('"')
+}
+
+// AppendURLEscaped appends s escaped like url.QueryEscape, so it can be
+// placed in a URL query.
+func (b *Buffer) AppendURLEscaped(s string) {
+	b.appendURLEscaped(s, true)
+}
+
+// AppendPathEscaped appends s escaped like url.PathEscape, so it can be
+// placed in a URL path segment.
+func (b *Buffer) AppendPathEscaped(s string) {
+	b.appendURLEscaped(s, false)
+}
+
+func (b *Buffer) appendURLEscaped(s string, query bool) {
+	b.checkUse()
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !shouldEscape(c, query) {
+			continue
+		}
+		b.bs = append(b.bs, s[start:i]...)
+		if c == ' ' && query {
+			b.bs = append(b.bs, '+')
+		} else {
+			b.bs = append(b.bs, '%', _upperHex[c>>4], _upperHex[c&0xf])
+		}
+		start = i + 1
+	}
+	b.bs = append(b.bs, s[start:]...)
+}
+
+const _upperHex = "0123456789ABCDEF"
+
+// shouldEscape follows the rules of net/url for query components and path
+// segments.
+func shouldEscape(c byte, query bool) bool {
+	if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+		return false
+	}
+	switch c {
+	case '-', '_', '.', '~':
+		return false
+	case '$', '&', '+', ':', '=', '@':
+		return query
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package buffer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var base64Encodings = []struct {
+	name string
+	enc  *base64.Encoding
+}{
+	{"Std", base64.StdEncoding},
+	{"RawStd", base64.RawStdEncoding},
+	{"URL", base64.URLEncoding},
+	{"RawURL", base64.RawURLEncoding},
+}
+
+func TestAppendEncoded(t *testing.T) {
+	buf := NewPool().Get()
+	src := []byte{0xfb, 0xff, 0x00, 0x10}
+
+	for _, e := range base64Encodings {
+		buf.Reset()
+		buf.AppendString("id=")
+		buf.AppendBase64(e.enc, src)
+		assert.Equal(t, "id="+e.enc.EncodeToString(src), buf.String(), e.name)
+	}
+
+	buf.Reset()
+	buf.AppendHex(src)
+	assert.Equal(t, "fbff0010", buf.String())
+
+	buf.Reset()
+	buf.AppendQuotedHex(src)
+	assert.Equal(t, `"fbff0010"`, buf.String())
+
+	buf.Reset()
+	buf.AppendURLEscaped("a b&c=d/é")
+	assert.Equal(t, "a+b%26c%3Dd%2F%C3%A9", buf.String())
+
+	buf.Reset()
+	buf.AppendPathEscaped("a b&c=d/é")
+	assert.Equal(t, "a%20b&c=d%2F%C3%A9", buf.String())
+}
+
+func TestAppendEncodedAllocs(t *testing.T) {
+	buf := NewPool().Get()
+	src := bytes.Repeat([]byte{0xab}, 32)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		buf.AppendBase64(base64.RawURLEncoding, src)
+		buf.AppendHex(src)
+		buf.AppendQuotedHex(src)
+		buf.AppendURLEscaped("a b/c")
+		buf.AppendPathEscaped("a b/c")
+	})
+	assert.Zero(t, allocs, "Expected no allocations")
+}
+
+func FuzzAppendBase64(f *testing.F) {
+	f.Add([]byte(""))
+	f.Add([]byte("f"))
+	f.Add([]byte{0xfb, 0xff, 0xfe})
+	f.Fuzz(func(t *testing.T, src []byte) {
+		buf := NewPool().Get()
+		defer buf.Free()
+		for _, e := range base64Encodings {
+			buf.Reset()
+			buf.AppendBase64(e.enc, src)
+			if want := e.enc.EncodeToString(src); buf.String() != want {
+				t.Fatalf("%s: got %q, want %q", e.name, buf.String(), want)
+			}
+			got, err := e.enc.DecodeString(buf.String())
+			if err != nil || !bytes.Equal(got, src) {
+				t.Fatalf("%s: round trip of %x gave %x, %v", e.name, src, got, err)
+			}
+		}
+	})
+}
+
+func FuzzAppendHex(f *testing.F) {
+	f.Add([]byte(""))
+	f.Add([]byte{0x00, 0xff})
+	f.Fuzz(func(t *testing.T, src []byte) {
+		buf := NewPool().Get()
+		defer buf.Free()
+		buf.AppendHex(src)
+		if want := hex.EncodeToString(src); buf.String() != want {
+			t.Fatalf("got %q, want %q", buf.String(), want)
+		}
+		got, err := hex.DecodeString(buf.String())
+		if err != nil || !bytes.Equal(got, src) {
+			t.Fatalf("round trip of %x gave %x, %v", src, got, err)
+		}
+		buf.Reset()
+		buf.AppendQuotedHex(src)
+		if want := `"` + hex.EncodeToString(src) + `"`; buf.String() != want {
+			t.Fatalf("quoted: got %q, want %q", buf.String(), want)
+		}
+	})
+}
+
+func FuzzAppendURLEscaped(f *testing.F) {
+	f.Add("")
+	f.Add("a b&c=d/e?f#g")
+	f.Add("$+,:;@~-_.%é\x00\xff")
+	f.Fuzz(func(t *testing.T, s string) {
+		buf := NewPool().Get()
+		defer buf.Free()
+		buf.AppendURLEscaped(s)
+		if want := url.QueryEscape(s); buf.String() != want {
+			t.Fatalf("query: got %q, want %q", buf.String(), want)
+		}
+		if got, err := url.QueryUnescape(buf.String()); err != nil || got != s {
+			t.Fatalf("query: round trip of %q gave %q, %v", s, got, err)
+		}
+		buf.Reset()
+		buf.AppendPathEscaped(s)
+		if want := url.PathEscape(s); buf.String() != want {
+			t.Fatalf("path: got %q, want %q", buf.String(), want)
+		}
+		if got, err := url.PathUnescape(buf.String()); err != nil || got != s {
+			t.Fatalf("path: round trip of %q gave %q, %v", s, got, err)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package buffer
+
+import (
+	"errors"
+	"io"
+)
+
+// MinRead is the minimum slice size passed to a Read call by
+// Buffer.ReadFrom, as in bytes.Buffer.
+const MinRead = 512
+
+var errUnreadByte = errors.New("buffer: UnreadByte: previous operation was not a successful read")
+
+var (
+	_ io.Reader     = (*Buffer)(nil)
+	_ io.ByteReader = (*Buffer)(nil)
+	_ io.ReaderFrom = (*Buffer)(nil)
+	_ io.WriterTo   = (*Buffer)(nil)
+)
+
+// Read reads the next len(p) bytes from the buffer or until the buffer is
+// drained. The return value n is the number of bytes read. If the buffer
+// has no data to return, err is io.EOF (unless len(p) is zero).
+func (b *Buffer) Read(p []byte) (n int, err error) {
+	b.checkUse()
+	b.lastRead = false
+	if b.off >= len(b.bs) {
+		// Buffer is empty, reset to recover space.
+		b.Reset()
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n = copy(p, b.bs[b.off:])
+	b.off += n
+	b.lastRead = n > 0
+	return n, nil
+}
+
+// ReadByte reads and returns the next byte from the buffer. If no byte is
+// available, it returns error io.EOF.
+func (b *Buffer) ReadByte() (byte, error) {
+	b.checkUse()
+	b.lastRead = false
+	if b.off >= len(b.bs) {
+		b.Reset()
+		return 0, io.EOF
+	}
+	c := b.bs[b.off]
+	b.off++
+	b.lastRead = true
+	return c, nil
+}
+
+// UnreadByte unreads the last byte returned by the most recent successful
+// read operation that read at least one byte.
+func (b *Buffer) UnreadByte() error {
+	b.checkUse()
+	if !b.lastRead {
+		return errUnreadByte
+	}
+	b.lastRead = false
+	b.off--
+	return nil
+}
+
+// ReadFrom reads data from r until EOF and appends it to the buffer,
+// growing the buffer as needed. The return value n is the number of bytes
+// read. Any error except io.EOF encountered during the read is also
+// returned.
+func (b *Buffer) ReadFrom(r io.Reader) (n int64, err error) {
+	b.checkUse()
+	b.lastRead = false
+	for {
+		b.Grow(MinRead)
+		m, e := r.Read(b.bs[len(b.bs):cap(b.bs)])
+		if m < 0 {
+			panic("buffer: reader returned negative count from Read")
+		}
+		b.bs = b.bs[:len(b.bs)+m]
+		n += int64(m)
+		if e == io.EOF {
+			return n, nil
+		}
+		if e != nil {
+			return n, e
+		}
+	}
+}
+
+// WriteTo writes data to w until the buffer is drained or an error occurs.
+// The return value n is the number of bytes written. Any error
+// encountered during the write is also returned.
+func (b *Buffer) WriteTo(w io.Writer) (n int64, err error) {
+	b.checkUse()
+	b.lastRead = false
+	if nBytes := b.Len(); nBytes > 0 {
+		m, e := w.Write(b.bs[b.off:])
+		if m > nBytes {
+			panic("buffer: invalid Write count")
+		}
+		b.off += m
+		n = int64(m)
+		if e != nil {
+			return n, e
+		}
+		if m != nBytes {
+			return n, io.ErrShortWrite
+		}
+	}
+	// Buffer is now empty; reset.
+	b.Reset()
+	return n, nil
+}
+
+// Truncate discards all but the first n unread bytes from the buffer but
+// continues to use the same allocated storage. It panics if n is negative
+// or greater than the length of the buffer.
+func (b *Buffer) Truncate(n int) {
+	b.checkUse()
+	if n == 0 {
+		b.Reset()
+		return
+	}
+	b.lastRead = false
+	if n < 0 || n > b.Len() {
+		panic("buffer: truncation out of range")
+	}
+	b.bs = b.bs[:b.off+n]
+}
+
+// Grow grows the buffer's capacity, if necessary, to guarantee space for
+// another n bytes. After Grow(n), at least n bytes can be written to the
+// buffer without another allocation. If n is negative, Grow panics.
+func (b *Buffer) Grow(n int) {
+	b.checkUse()
+	if n < 0 {
+		panic("buffer: negative count")
+	}
+	if cap(b.bs)-len(b.bs) >= n {
+		return
+	}
+	if m := b.Len(); b.off > 0 && m+n <= cap(b.bs) {
+		// Slide the unread bytes down instead of reallocating.
+		copy(b.bs, b.bs[b.off:])
+		b.bs = b.bs[:m]
+		b.off = 0
+		b.lastRead = false
+		return
+	}
+	b.bs = append(b.bs, make([]byte, n)...)[:len(b.bs)]
+}
+
+// Available returns how many bytes are unused in the buffer.
+func (b *Buffer) Available() int {
+	b.checkUse()
+	return cap(b.bs) - len(b.bs)
+}
@@ -0,0 +1,134 @@
+package buffer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferRead(t *testing.T) {
+	buf := NewPool().Get()
+	defer buf.Free()
+	buf.AppendString("hello world")
+
+	p := make([]byte, 5)
+	n, err := buf.Read(p)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(p[:n]))
+	assert.Equal(t, 6, buf.Len())
+	assert.Equal(t, " world", buf.String())
+
+	c, err := buf.ReadByte()
+	require.NoError(t, err)
+	assert.Equal(t, byte(' '), c)
+	require.NoError(t, buf.UnreadByte())
+	assert.Error(t, buf.UnreadByte(), "Expected second UnreadByte to fail")
+	assert.Equal(t, " world", string(buf.Bytes()))
+
+	rest, err := io.ReadAll(buf)
+	require.NoError(t, err)
+	assert.Equal(t, " world", string(rest))
+	assert.Zero(t, buf.Len())
+
+	n, err = buf.Read(p)
+	assert.Zero(t, n)
+	assert.Equal(t, io.EOF, err)
+	_, err = buf.ReadByte()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestBufferReaderConformance(t *testing.T) {
+	const content = "the quick brown fox jumps over the lazy dog"
+	buf := NewPool().Get()
+	defer buf.Free()
+	buf.AppendString(content)
+	assert.NoError(t, iotest.TestReader(buf, []byte(content)))
+}
+
+func TestBufferReadFrom(t *testing.T) {
+	data := strings.Repeat("0123456789", 1000)
+	buf := NewPool().Get()
+	defer buf.Free()
+	buf.AppendString("prefix:")
+
+	n, err := buf.ReadFrom(iotest.OneByteReader(strings.NewReader(data)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.Equal(t, "prefix:"+data, buf.String())
+
+	boom := errors.New("boom")
+	_, err = buf.ReadFrom(iotest.ErrReader(boom))
+	assert.Equal(t, boom, err)
+}
+
+func TestBufferWriteTo(t *testing.T) {
+	buf := NewPool().Get()
+	defer buf.Free()
+	buf.AppendString("hello world")
+	buf.Read(make([]byte, 6))
+
+	var out bytes.Buffer
+	n, err := buf.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Equal(t, "world", out.String())
+	assert.Zero(t, buf.Len())
+
+	buf.AppendString("again")
+	_, err = buf.WriteTo(iotest.TruncateWriter(&out, 2))
+	assert.NoError(t, err, "TruncateWriter reports full writes")
+}
+
+func TestBufferTruncateGrow(t *testing.T) {
+	buf := NewPoolSize(16).Get()
+	defer buf.Free()
+	buf.AppendString("hello world")
+	buf.ReadByte()
+
+	buf.Truncate(4)
+	assert.Equal(t, "ello", buf.String())
+	assert.Panics(t, func() { buf.Truncate(5) })
+	assert.Panics(t, func() { buf.Truncate(-1) })
+	assert.Equal(t, 16-5, buf.Available())
+
+	// Sliding the unread bytes down makes room without reallocating.
+	buf.Grow(12)
+	assert.Equal(t, 16, buf.Cap())
+	assert.Equal(t, "ello", buf.String())
+	assert.GreaterOrEqual(t, buf.Available(), 12)
+
+	buf.Grow(100)
+	assert.GreaterOrEqual(t, buf.Available(), 100)
+	assert.Equal(t, "ello", buf.String())
+	assert.Panics(t, func() { buf.Grow(-1) })
+
+	buf.Truncate(0)
+	assert.Zero(t, buf.Len())
+}
+
+func TestBufferNewlineAfterRead(t *testing.T) {
+	buf := NewPool().Get()
+	defer buf.Free()
+
+	buf.AppendString("a\nbc")
+	buf.Read(make([]byte, 2))
+	buf.WriteNewLine()
+	assert.Equal(t, "bc\n", buf.String())
+	buf.WriteNewLine()
+	assert.Equal(t, "bc\n", buf.String())
+	buf.TrimNewline()
+	assert.Equal(t, "bc", buf.String())
+
+	buf.Reset()
+	buf.AppendString("x\n")
+	buf.Read(make([]byte, 2))
+	buf.TrimNewline()
+	assert.Zero(t, buf.Len())
+	assert.Equal(t, "", buf.String())
+}
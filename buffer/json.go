@@ -0,0 +1,136 @@
+package buffer
+
+import "unicode/utf8"
+
+const _hex = "0123456789abcdef"
+
+// AppendJSONString appends s as a quoted JSON string. Like encoding/json,
+// it escapes '<', '>' and '&' so the output is safe to embed in HTML,
+// escapes U+2028 and U+2029, and replaces invalid UTF-8 with U+FFFD.
+func (b *Buffer) AppendJSONString(s string) {
+	b.checkUse()
+	b.bs = append(b.bs, '"')
+	b.appendEscaped(s, true)
+	b.bs = append(b.bs, '"')
+}
+
+// AppendJSONKey appends s as a JSON object key followed by a colon,
+// preceded by a comma if the key is not the first in its object.
+func (b *Buffer) AppendJSONKey(s string) {
+	b.checkUse()
+	b.AppendElementSeparator()
+	b.AppendJSONString(s)
+	b.bs = append(b.bs, ':')
+}
+
+// AppendObjectBegin appends the opening brace of a JSON object, preceded
+// by a comma if the object is not the first element of its array. An
+// object that is not nested in one opened with AppendObjectBegin or
+// AppendArrayBegin is appended as is, whatever precedes it.
+func (b *Buffer) AppendObjectBegin() {
+	b.checkUse()
+	b.beginJSON('{')
+}
+
+// AppendObjectEnd appends the closing brace of a JSON object.
+func (b *Buffer) AppendObjectEnd() {
+	b.checkUse()
+	b.endJSON('}')
+}
+
+// AppendArrayBegin appends the opening bracket of a JSON array, preceded by
+// a comma if the array is not the first element of its array. Like
+// AppendObjectBegin, it adds no comma outside of a JSON object or array.
+func (b *Buffer) AppendArrayBegin() {
+	b.checkUse()
+	b.beginJSON('[')
+}
+
+// AppendArrayEnd appends the closing bracket of a JSON array.
+func (b *Buffer) AppendArrayEnd() {
+	b.checkUse()
+	b.endJSON(']')
+}
+
+func (b *Buffer) beginJSON(c byte) {
+	// Outside of a container the buffer may hold anything, such as the
+	// prefix of a log line, so its last byte says nothing.
+	if b.depth > 0 {
+		b.AppendElementSeparator()
+	}
+	b.depth++
+	b.bs = append(b.bs, c)
+}
+
+func (b *Buffer) endJSON(c byte) {
+	if b.depth > 0 {
+		b.depth--
+	}
+	b.bs = append(b.bs, c)
+}
+
+// AppendElementSeparator appends a comma unless the buffer is empty or
+// ends with an opening brace or bracket, a colon, a comma or a newline.
+// Call it before each element of a JSON array.
+func (b *Buffer) AppendElementSeparator() {
+	b.checkUse()
+	if len(b.bs) == 0 {
+		return
+	}
+	switch b.bs[len(b.bs)-1] {
+	case '{', '[', ':', ',', '\n':
+		return
+	}
+	b.bs = append(b.bs, ',')
+}
+
+// appendEscaped appends s with the escaping of a JSON string, leaving
+// '<', '>' and '&' alone unless html is set.
+func (b *Buffer) appendEscaped(s string, html bool) {
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && (!html || c != '<' && c != '>' && c != '&') {
+				i++
+				continue
+			}
+			b.bs = append(b.bs, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b.bs = append(b.bs, '\\', c)
+			case '\b':
+				b.bs = append(b.bs, '\\', 'b')
+			case '\f':
+				b.bs = append(b.bs, '\\', 'f')
+			case '\n':
+				b.bs = append(b.bs, '\\', 'n')
+			case '\r':
+				b.bs = append(b.bs, '\\', 'r')
+			case '\t':
+				b.bs = append(b.bs, '\\', 't')
+			default:
+				b.bs = append(b.bs, '\\', 'u', '0', '0', _hex[c>>4], _hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.bs = append(b.bs, s[start:i]...)
+			b.bs = append(b.bs, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b.bs = append(b.bs, s[start:i]...)
+			b.bs = append(b.bs, '\\', 'u', '2', '0', '2', _hex[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b.bs = append(b.bs, s[start:]...)
+}
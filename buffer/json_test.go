@@ -0,0 +1,93 @@
+package buffer
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendJSONString(t *testing.T) {
+	tests := []string{
+		"",
+		"plain",
+		`quote " and backslash \`,
+		"control \x00\x01\b\f\n\r\t\x1f\x7f",
+		"<script>&amp;</script>",
+		"line\u2028para\u2029sep",
+		"invalid \xff\xfe utf-8 \xc3",
+		"unicode 日本語 🎉",
+	}
+	buf := NewPool().Get()
+	for _, s := range tests {
+		buf.Reset()
+		buf.AppendJSONString(s)
+		want, err := json.Marshal(s)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), buf.String(), "Unexpected encoding of %q", s)
+	}
+}
+
+func TestAppendJSONStructure(t *testing.T) {
+	buf := NewPool().Get()
+	buf.AppendObjectBegin()
+	buf.AppendJSONKey("a")
+	buf.AppendInt(1)
+	buf.AppendJSONKey("b")
+	buf.AppendArrayBegin()
+	for _, s := range []string{"x", "y"} {
+		buf.AppendElementSeparator()
+		buf.AppendJSONString(s)
+	}
+	buf.AppendObjectBegin()
+	buf.AppendObjectEnd()
+	buf.AppendArrayBegin()
+	buf.AppendArrayEnd()
+	buf.AppendArrayEnd()
+	buf.AppendJSONKey("c")
+	buf.AppendObjectBegin()
+	buf.AppendJSONKey("d")
+	buf.AppendBool(true)
+	buf.AppendObjectEnd()
+	buf.AppendObjectEnd()
+
+	assert.Equal(t, `{"a":1,"b":["x","y",{},[]],"c":{"d":true}}`, buf.String())
+	assert.True(t, json.Valid(buf.Bytes()))
+}
+
+func TestAppendJSONAfterPrefix(t *testing.T) {
+	buf := NewPool().Get()
+	for _, prefix := range []string{"INFO msg ", "INFO 1", `x"`, "line\n"} {
+		buf.Reset()
+		buf.AppendString(prefix)
+		buf.AppendObjectBegin()
+		buf.AppendJSONKey("a")
+		buf.AppendInt(1)
+		buf.AppendJSONKey("b")
+		buf.AppendArrayBegin()
+		buf.AppendObjectBegin()
+		buf.AppendObjectEnd()
+		buf.AppendObjectBegin()
+		buf.AppendObjectEnd()
+		buf.AppendArrayEnd()
+		buf.AppendObjectEnd()
+		assert.Equal(t, prefix+`{"a":1,"b":[{},{}]}`, buf.String())
+
+		buf.AppendArrayBegin()
+		buf.AppendArrayEnd()
+		assert.Equal(t, prefix+`{"a":1,"b":[{},{}]}[]`, buf.String(), "Expected no comma between top-level values.")
+	}
+}
+
+func TestAppendJSONAllocs(t *testing.T) {
+	buf := NewPool().Get()
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		buf.AppendObjectBegin()
+		buf.AppendJSONKey("msg")
+		buf.AppendJSONString("<tag> \"quoted\" \n \xff 日本")
+		buf.AppendObjectEnd()
+	})
+	assert.Zero(t, allocs, "Expected no allocations")
+}
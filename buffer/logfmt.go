@@ -0,0 +1,391 @@
+package buffer
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// AppendLogfmt appends key=value in logfmt, preceded by a space unless the
+// buffer is empty or ends with a space or newline. Maps, structs and
+// slices are flattened into one pair per leaf, with the keys joined by
+// dots ("a.b=1 a.c=2"); struct fields are named by their json tag if they
+// have one, and values implementing Marshaler or ArrayMarshaler encode
+// themselves. Nil maps and slices are written as null and empty ones as
+// {} or [], so that the key is never lost; values nested more than
+// maxLogfmtDepth levels deep, as in cyclic data, are replaced by an
+// error. Scalar values are appended without allocating.
+func (b *Buffer) AppendLogfmt(key string, value any) {
+	b.appendLogfmt(key, value, 0)
+}
+
+// maxLogfmtDepth is how deep AppendLogfmt follows nested values.
+const maxLogfmtDepth = 32
+
+func (b *Buffer) appendLogfmt(key string, value any, depth int) {
+	switch v := value.(type) {
+	case nil:
+		b.AppendLogfmtKey(key)
+		b.AppendString("null")
+	case string:
+		b.AppendLogfmtKey(key)
+		b.AppendLogfmtString(v)
+	case []byte:
+		b.AppendLogfmtKey(key)
+		b.appendLogfmtBytes(v)
+	case bool:
+		b.AppendLogfmtKey(key)
+		b.AppendBool(v)
+	case int:
+		b.AppendLogfmtKey(key)
+		b.AppendInt(int64(v))
+	case int8:
+		b.AppendLogfmtKey(key)
+		b.AppendInt(int64(v))
+	case int16:
+		b.AppendLogfmtKey(key)
+		b.AppendInt(int64(v))
+	case int32:
+		b.AppendLogfmtKey(key)
+		b.AppendInt(int64(v))
+	case int64:
+		b.AppendLogfmtKey(key)
+		b.AppendInt(v)
+	case uint:
+		b.AppendLogfmtKey(key)
+		b.AppendUint(uint64(v))
+	case uint8:
+		b.AppendLogfmtKey(key)
+		b.AppendUint(uint64(v))
+	case uint16:
+		b.AppendLogfmtKey(key)
+		b.AppendUint(uint64(v))
+	case uint32:
+		b.AppendLogfmtKey(key)
+		b.AppendUint(uint64(v))
+	case uint64:
+		b.AppendLogfmtKey(key)
+		b.AppendUint(v)
+	case float32:
+		b.AppendLogfmtKey(key)
+		b.AppendFloat(float64(v), 32)
+	case float64:
+		b.AppendLogfmtKey(key)
+		b.AppendFloat(v, 64)
+	case time.Time:
+		b.AppendLogfmtKey(key)
+		b.AppendTime(v, time.RFC3339Nano)
+	case time.Duration:
+		b.AppendLogfmtKey(key)
+		b.appendDurationString(v)
+	case json.Number:
+		b.AppendLogfmtKey(key)
+		b.AppendLogfmtString(v.String())
+	case Marshaler:
+		n := len(b.bs)
+		e := &LogfmtEncoder{b: b, prefix: key + "."}
+		if err := v.MarshalObject(e); err != nil {
+			b.AppendLogfmt(key+"Error", err.Error())
+		}
+		b.appendLogfmtEmpty(key, n, "{}")
+	case ArrayMarshaler:
+		n := len(b.bs)
+		if err := NewLogfmtEncoder(b).AddArray(key, v); err != nil {
+			b.AppendLogfmt(key+"Error", err.Error())
+		}
+		b.appendLogfmtEmpty(key, n, "[]")
+	case error:
+		b.AppendLogfmtKey(key)
+		b.AppendLogfmtString(v.Error())
+	case fmt.Stringer:
+		b.AppendLogfmtKey(key)
+		b.AppendLogfmtString(v.String())
+	case encoding.TextMarshaler:
+		b.AppendLogfmtKey(key)
+		text, err := v.MarshalText()
+		if err != nil {
+			b.AppendLogfmtString(err.Error())
+		} else {
+			b.appendLogfmtBytes(text)
+		}
+	default:
+		b.appendLogfmtReflect(key, reflect.ValueOf(value), depth)
+	}
+}
+
+// appendLogfmtEmpty appends key=empty if nothing was appended since the
+// buffer had n bytes.
+func (b *Buffer) appendLogfmtEmpty(key string, n int, empty string) {
+	if len(b.bs) == n {
+		b.AppendLogfmtKey(key)
+		b.AppendString(empty)
+	}
+}
+
+// AppendLogfmtKey appends a logfmt key and the equals sign, preceded by a
+// space unless the buffer is empty or ends with a space or newline. Bytes
+// not allowed in a key (spaces, control characters, '=', '"' and invalid
+// UTF-8) are replaced with underscores.
+func (b *Buffer) AppendLogfmtKey(key string) {
+	b.checkUse()
+	if n := len(b.bs); n > 0 && b.bs[n-1] != ' ' && b.bs[n-1] != '\n' {
+		b.bs = append(b.bs, ' ')
+	}
+	if key == "" {
+		key = "_"
+	}
+	start := 0
+	for i := 0; i < len(key); {
+		r, size := rune(key[i]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(key[i:])
+		}
+		if logfmtNeedsQuote(r, size) {
+			b.bs = append(b.bs, key[start:i]...)
+			b.bs = append(b.bs, '_')
+			start = i + size
+		}
+		i += size
+	}
+	b.bs = append(b.bs, key[start:]...)
+	b.bs = append(b.bs, '=')
+}
+
+// AppendLogfmtString appends s as a logfmt value. The value is quoted and
+// escaped if it is empty or contains spaces, control characters, '=', '"'
+// or invalid UTF-8.
+func (b *Buffer) AppendLogfmtString(s string) {
+	b.checkUse()
+	if !logfmtQuote(s) {
+		b.bs = append(b.bs, s...)
+		return
+	}
+	b.bs = append(b.bs, '"')
+	b.appendEscaped(s, false)
+	b.bs = append(b.bs, '"')
+}
+
+func (b *Buffer) appendLogfmtBytes(v []byte) {
+	// The conversion does not allocate when its result does not escape.
+	b.AppendLogfmtString(string(v))
+}
+
+func (b *Buffer) appendLogfmtReflect(key string, v reflect.Value, depth int) {
+	if depth >= maxLogfmtDepth {
+		b.AppendLogfmt(key+"Error", "maximum depth exceeded")
+		return
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			b.AppendLogfmtKey(key)
+			b.AppendString("null")
+			return
+		}
+		v = v.Elem()
+	}
+	n := len(b.bs)
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			b.AppendLogfmtKey(key)
+			b.AppendString("null")
+			return
+		}
+		keys := v.MapKeys()
+		names := make([]string, len(keys))
+		for i, k := range keys {
+			names[i] = mapKeyString(k)
+		}
+		sort.Sort(byName{names, keys})
+		for i, k := range keys {
+			b.appendLogfmt(key+"."+names[i], v.MapIndex(k).Interface(), depth+1)
+		}
+		b.appendLogfmtEmpty(key, n, "{}")
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := f.Name
+			if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			b.appendLogfmt(key+"."+name, v.Field(i).Interface(), depth+1)
+		}
+		b.appendLogfmtEmpty(key, n, "{}")
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			b.AppendLogfmtKey(key)
+			b.AppendString("null")
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			b.appendLogfmt(key+"."+strconv.Itoa(i), v.Index(i).Interface(), depth+1)
+		}
+		b.appendLogfmtEmpty(key, n, "[]")
+	case reflect.String:
+		b.AppendLogfmtKey(key)
+		b.AppendLogfmtString(v.String())
+	case reflect.Bool:
+		b.AppendLogfmtKey(key)
+		b.AppendBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.AppendLogfmtKey(key)
+		b.AppendInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		b.AppendLogfmtKey(key)
+		b.AppendUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		b.AppendLogfmtKey(key)
+		b.AppendFloat(v.Float(), v.Type().Bits())
+	default:
+		b.AppendLogfmtKey(key)
+		b.AppendLogfmtString(fmt.Sprint(v.Interface()))
+	}
+}
+
+func mapKeyString(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+type byName struct {
+	names []string
+	keys  []reflect.Value
+}
+
+func (s byName) Len() int           { return len(s.names) }
+func (s byName) Less(i, j int) bool { return s.names[i] < s.names[j] }
+func (s byName) Swap(i, j int) {
+	s.names[i], s.names[j] = s.names[j], s.names[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+func logfmtQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for i := 0; i < len(s); {
+		r, size := rune(s[i]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(s[i:])
+		}
+		if logfmtNeedsQuote(r, size) {
+			return true
+		}
+		i += size
+	}
+	return false
+}
+
+func logfmtNeedsQuote(r rune, size int) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == 0x7f ||
+		r == utf8.RuneError && size == 1
+}
+
+// LogfmtEncoder encodes objects as logfmt pairs. Nested objects and
+// arrays are flattened like in AppendLogfmt, with keys such as "a.b" and
+// "a.0".
+type LogfmtEncoder struct {
+	b      *Buffer
+	prefix string
+}
+
+var _ ObjectEncoder = (*LogfmtEncoder)(nil)
+
+// NewLogfmtEncoder returns a LogfmtEncoder writing to b.
+func NewLogfmtEncoder(b *Buffer) *LogfmtEncoder {
+	return &LogfmtEncoder{b: b}
+}
+
+func (e *LogfmtEncoder) key(key string) {
+	if e.prefix == "" {
+		e.b.AppendLogfmtKey(key)
+		return
+	}
+	e.b.AppendLogfmtKey(e.prefix + key)
+}
+
+func (e *LogfmtEncoder) AddString(key, value string) {
+	e.key(key)
+	e.b.AppendLogfmtString(value)
+}
+
+func (e *LogfmtEncoder) AddInt64(key string, value int64) {
+	e.key(key)
+	e.b.AppendInt(value)
+}
+
+func (e *LogfmtEncoder) AddUint64(key string, value uint64) {
+	e.key(key)
+	e.b.AppendUint(value)
+}
+
+func (e *LogfmtEncoder) AddFloat64(key string, value float64) {
+	e.key(key)
+	e.b.AppendFloat(value, 64)
+}
+
+func (e *LogfmtEncoder) AddBool(key string, value bool) {
+	e.key(key)
+	e.b.AppendBool(value)
+}
+
+func (e *LogfmtEncoder) AddTime(key string, value time.Time) {
+	e.key(key)
+	e.b.AppendTime(value, time.RFC3339Nano)
+}
+
+func (e *LogfmtEncoder) AddDuration(key string, value time.Duration) {
+	e.key(key)
+	e.b.appendDurationString(value)
+}
+
+func (e *LogfmtEncoder) AddObject(key string, value Marshaler) error {
+	prefix := e.prefix
+	e.prefix = prefix + key + "."
+	err := value.MarshalObject(e)
+	e.prefix = prefix
+	return err
+}
+
+func (e *LogfmtEncoder) AddArray(key string, value ArrayMarshaler) error {
+	return value.MarshalArray(&logfmtArray{e: e, key: key})
+}
+
+// logfmtArray encodes each element as a field keyed by its index.
+type logfmtArray struct {
+	e   *LogfmtEncoder
+	key string
+	i   int
+}
+
+func (a *logfmtArray) next() string {
+	k := a.key + "." + strconv.Itoa(a.i)
+	a.i++
+	return k
+}
+
+func (a *logfmtArray) AppendString(v string)          { a.e.AddString(a.next(), v) }
+func (a *logfmtArray) AppendInt64(v int64)            { a.e.AddInt64(a.next(), v) }
+func (a *logfmtArray) AppendUint64(v uint64)          { a.e.AddUint64(a.next(), v) }
+func (a *logfmtArray) AppendFloat64(v float64)        { a.e.AddFloat64(a.next(), v) }
+func (a *logfmtArray) AppendBool(v bool)              { a.e.AddBool(a.next(), v) }
+func (a *logfmtArray) AppendTime(v time.Time)         { a.e.AddTime(a.next(), v) }
+func (a *logfmtArray) AppendDuration(v time.Duration) { a.e.AddDuration(a.next(), v) }
+func (a *logfmtArray) AppendObject(v Marshaler) error { return a.e.AddObject(a.next(), v) }
+func (a *logfmtArray) AppendArray(v ArrayMarshaler) error {
+	return a.e.AddArray(a.next(), v)
+}
@@ -0,0 +1,134 @@
+package buffer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type logfmtLevel int
+
+type logfmtUser struct {
+	Name   string            `json:"name"`
+	Age    int               `json:"age,omitempty"`
+	Secret string            `json:"-"`
+	Tags   []string          `json:"tags"`
+	Meta   map[string]any    `json:"meta"`
+	Extra  map[string]string `json:"extra"`
+	hidden bool
+}
+
+func TestAppendLogfmt(t *testing.T) {
+	tests := []struct {
+		desc  string
+		key   string
+		value any
+		want  string
+	}{
+		{"Bare", "msg", "hello", "msg=hello"},
+		{"Empty", "msg", "", `msg=""`},
+		{"Space", "msg", "hello world", `msg="hello world"`},
+		{"Equals", "msg", "a=b", `msg="a=b"`},
+		{"Quote", "msg", `say "hi"`, `msg="say \"hi\""`},
+		{"Newline", "msg", "a\nb", `msg="a\nb"`},
+		{"InvalidUTF8", "msg", "a\xffb", "msg=\"a\ufffdb\""},
+		{"Backslash", "path", `C:\dir`, `path=C:\dir`},
+		{"HTML", "msg", "<b>&</b>", "msg=<b>&</b>"},
+		{"BadKey", "a b=c\"", 1, "a_b_c_=1"},
+		{"EmptyKey", "", 1, "_=1"},
+		{"Int", "n", -42, "n=-42"},
+		{"Uint", "n", uint8(42), "n=42"},
+		{"Float", "f", 3.5, "f=3.5"},
+		{"Bool", "ok", true, "ok=true"},
+		{"Nil", "v", nil, "v=null"},
+		{"NilPointer", "v", (*logfmtUser)(nil), "v=null"},
+		{"Bytes", "b", []byte("x y"), `b="x y"`},
+		{"Error", "err", errors.New("boom: bad thing"), `err="boom: bad thing"`},
+		{"Duration", "d", 1500 * time.Millisecond, "d=1.5s"},
+		{"Time", "t", time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC), "t=2000-01-02T03:04:05Z"},
+		{"NamedInt", "level", logfmtLevel(3), "level=3"},
+		{"Map", "m", map[string]int{"b": 2, "a": 1}, "m.a=1 m.b=2"},
+		{"Slice", "s", []int{1, 2}, "s.0=1 s.1=2"},
+		{
+			"Struct", "user",
+			&logfmtUser{
+				Name:   "Ann Lee",
+				Secret: "x",
+				Tags:   []string{"a"},
+				Meta:   map[string]any{"nested": map[string]any{"k": "v"}},
+				hidden: true,
+			},
+			`user.name="Ann Lee" user.age=0 user.tags.0=a user.meta.nested.k=v user.extra=null`,
+		},
+		{"EmptySlice", "tags", []string{}, "tags=[]"},
+		{"NilSlice", "tags", []string(nil), "tags=null"},
+		{"EmptyArray", "a", [0]int{}, "a=[]"},
+		{"EmptyMap", "m", map[string]int{}, "m={}"},
+		{"NilMap", "m", map[string]int(nil), "m=null"},
+		{"EmptyStruct", "s", struct{ hidden int }{}, "s={}"},
+		{"EmptyNested", "s", map[string]any{"a": []int{}, "b": struct{}{}}, "s.a=[] s.b={}"},
+		{"DurationSmall", "d", 1500 * time.Microsecond, "d=1.5ms"},
+		{"DurationMicro", "d", 2 * time.Microsecond, "d=2µs"},
+		{"DurationNano", "d", time.Duration(7), "d=7ns"},
+		{"DurationZero", "d", time.Duration(0), "d=0s"},
+		{"DurationLong", "d", -(26*time.Hour + 3*time.Minute + 500*time.Millisecond), "d=-26h3m0.5s"},
+	}
+
+	buf := NewPool().Get()
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			buf.Reset()
+			buf.AppendLogfmt(tt.key, tt.value)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestAppendLogfmtLine(t *testing.T) {
+	buf := NewPool().Get()
+	buf.AppendLogfmt("level", "info")
+	buf.AppendLogfmt("msg", "started server")
+	buf.AppendLogfmt("port", 8080)
+	buf.AppendByte('\n')
+	buf.AppendLogfmt("level", "debug")
+	assert.Equal(t, "level=info msg=\"started server\" port=8080\nlevel=debug", buf.String())
+}
+
+type logfmtNode struct {
+	Name string
+	Next *logfmtNode
+}
+
+func TestAppendLogfmtCycle(t *testing.T) {
+	n := &logfmtNode{Name: "a"}
+	n.Next = n
+	m := map[string]any{}
+	m["self"] = m
+
+	buf := NewPool().Get()
+	for _, v := range []any{n, m} {
+		buf.Reset()
+		buf.AppendLogfmt("v", v)
+		assert.Contains(t, buf.String(), `Error="maximum depth exceeded"`)
+	}
+}
+
+func TestAppendLogfmtAllocs(t *testing.T) {
+	buf := NewPool().Get()
+	var bs any = []byte("bytes")
+	var d any = 1500 * time.Millisecond
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		buf.AppendLogfmt("msg", "hello world")
+		buf.AppendLogfmt("n", 42)
+		buf.AppendLogfmt("ok", true)
+		buf.AppendLogfmt("b", bs)
+		buf.AppendLogfmt("d", d)
+		NewLogfmtEncoder(buf).AddDuration("took", 90*time.Minute)
+		buf.AppendLogfmtKey("level")
+		buf.AppendLogfmtString("info")
+	})
+	assert.Zero(t, allocs, "Expected no allocations")
+}
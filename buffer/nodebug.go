@@ -0,0 +1,17 @@
+//go:build !bufferdebug
+
+package buffer
+
+// debugEnabled reports whether the bufferdebug build tag is set.
+const debugEnabled = false
+
+// debugState is empty unless the bufferdebug build tag is set.
+type debugState struct{}
+
+func debugAlloc(*Buffer) {}
+
+func debugGet(*Buffer) {}
+
+func debugPut(b *Buffer) *Buffer { return b }
+
+func (b *Buffer) checkUse() {}
@@ -1,46 +1,177 @@
 package buffer
 
 import (
+	"math/bits"
+	"sync/atomic"
+
 	"github.com/millken/gosync"
 )
 
+// _maxSize is the largest capacity a default Pool retains. Bigger buffers
+// are left to the garbage collector when freed.
+const _maxSize = 64 << 10
+
 var (
 	_pool = NewPool()
 	// Get retrieves a buffer from the pool, creating one if necessary.
 	Get = _pool.Get
+	// GetSize retrieves a buffer with a capacity of at least n bytes from the
+	// pool, creating one if necessary.
+	GetSize = _pool.GetSize
 )
 
-// A Pool is a type-safe wrapper around a sync.Pool.
+// A Pool is a type-safe wrapper around a set of sync.Pools, one for each
+// power-of-two size class.
 type Pool struct {
-	p *gosync.Pool[*Buffer]
+	c *classes
+}
+
+type classes struct {
+	min   int // capacity of the smallest class
+	max   int // largest capacity put back into a class
+	pools []*gosync.Pool[*Buffer]
+
+	gets, puts, allocs, drops, putBytes atomic.Int64
+}
+
+// Stats are the counters of a Pool.
+type Stats struct {
+	Gets   int64 // buffers handed out by Get and GetSize
+	Puts   int64 // buffers handed back by Free
+	Allocs int64 // buffers allocated because no pooled one was available
+	Drops  int64 // freed buffers not retained because of their capacity
+
+	// PutBytes is the total capacity in bytes of the buffers Free has
+	// handed back to the pool and not dropped. It is cumulative: neither
+	// reuse by Get nor the garbage collector clearing the pool subtracts
+	// from it, so it says how much memory the pool recycled rather than
+	// how much it holds.
+	PutBytes int64
 }
 
 // NewPool constructs a new Pool.
 func NewPool() Pool {
-	return Pool{
-		p: gosync.NewPool(func() *Buffer {
-			return &Buffer{bs: make([]byte, 0, _size)}
-		}),
-	}
+	return NewPoolClasses(_size, _maxSize)
 }
 
-// NewPoolSize constructs a new Pool.
+// NewPoolSize constructs a new Pool whose Get returns buffers of at least
+// size bytes.
 func NewPoolSize(size int) Pool {
-	return Pool{
-		p: gosync.NewPool(func() *Buffer {
-			return &Buffer{bs: make([]byte, 0, size)}
-		}),
+	return NewPoolClasses(size, maxInt(size, _maxSize))
+}
+
+// NewPoolClasses constructs a new Pool with size classes from minSize to
+// maxSize bytes, both rounded up to a power of two. Buffers that have grown
+// beyond maxSize are dropped by Free rather than retained.
+func NewPoolClasses(minSize, maxSize int) Pool {
+	minSize = roundPow2(maxInt(minSize, 1))
+	maxSize = roundPow2(maxInt(maxSize, minSize))
+	c := &classes{min: minSize, max: maxSize}
+	for size := minSize; size <= maxSize; size <<= 1 {
+		// Classes return nil when empty, so that get can try the next.
+		c.pools = append(c.pools, gosync.NewPool(func() *Buffer { return nil }))
 	}
+	return Pool{c: c}
 }
 
-// Get retrieves a Buffer from the pool, creating one if necessary.
+// Get retrieves a Buffer from the smallest size class that has one,
+// creating one if necessary.
 func (p Pool) Get() *Buffer {
-	buf := p.p.Get()
+	return p.get(0)
+}
+
+// GetSize retrieves a Buffer with a capacity of at least n bytes from the
+// smallest size class that has one and is big enough, creating one if
+// necessary. Requests larger than the largest class are allocated
+// directly.
+func (p Pool) GetSize(n int) *Buffer {
+	if n > p.c.max {
+		p.c.gets.Add(1)
+		buf := p.c.alloc(n)
+		buf.pool = p
+		debugGet(buf)
+		return buf
+	}
+	return p.get(p.c.class(n))
+}
+
+// get retrieves a Buffer from class i or, since freed buffers are filed
+// by their grown capacity, from a larger class.
+func (p Pool) get(i int) *Buffer {
+	p.c.gets.Add(1)
+	var buf *Buffer
+	for j := i; j < len(p.c.pools) && buf == nil; j++ {
+		buf = p.c.pools[j].Get()
+	}
+	if buf == nil {
+		buf = p.c.alloc(p.c.min << i)
+	}
 	buf.Reset()
 	buf.pool = p
+	debugGet(buf)
 	return buf
 }
 
 func (p Pool) put(buf *Buffer) {
-	p.p.Put(buf)
+	if buf = debugPut(buf); buf == nil {
+		return
+	}
+	p.c.puts.Add(1)
+	n := cap(buf.bs)
+	if n < p.c.min || n > p.c.max {
+		p.c.drops.Add(1)
+		return
+	}
+	p.c.putBytes.Add(int64(n))
+	// File the buffer under the largest class it can satisfy, so that
+	// every buffer in a class has at least the class capacity.
+	i := bits.Len(uint(n)) - bits.Len(uint(p.c.min))
+	p.c.pools[i].Put(buf)
+}
+
+// Stats returns a snapshot of the pool's counters.
+func (p Pool) Stats() Stats {
+	return Stats{
+		Gets:     p.c.gets.Load(),
+		Puts:     p.c.puts.Load(),
+		Allocs:   p.c.allocs.Load(),
+		Drops:    p.c.drops.Load(),
+		PutBytes: p.c.putBytes.Load(),
+	}
+}
+
+func (c *classes) alloc(size int) *Buffer {
+	c.allocs.Add(1)
+	buf := &Buffer{bs: make([]byte, 0, size)}
+	debugAlloc(buf)
+	return buf
+}
+
+// class returns the index of the smallest class holding n bytes.
+func (c *classes) class(n int) int {
+	if n <= c.min {
+		return 0
+	}
+	return bits.Len(uint(n-1)) - bits.Len(uint(c.min-1))
+}
+
+func roundPow2(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
 }
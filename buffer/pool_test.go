@@ -1,6 +1,7 @@
 package buffer
 
 import (
+	"strings"
 	"sync"
 	"testing"
 
@@ -30,3 +31,90 @@ func TestBuffers(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestPoolSizeClasses(t *testing.T) {
+	p := NewPoolClasses(1000, 5000)
+
+	buf := p.Get()
+	assert.Equal(t, 1024, buf.Cap(), "Expected smallest class rounded up to a power of two")
+	buf.Free()
+	for _, tt := range []struct{ n, want int }{
+		{0, 1024},
+		{1024, 1024},
+		{1025, 2048},
+		{4096, 4096},
+		{8192, 8192},
+		{8193, 8193},
+	} {
+		buf := p.GetSize(tt.n)
+		assert.Zero(t, buf.Len(), "Expected truncated buffer")
+		assert.Equal(t, tt.want, buf.Cap(), "Unexpected capacity for GetSize(%d)", tt.n)
+		buf.Free()
+	}
+}
+
+func TestPoolMaxRetained(t *testing.T) {
+	p := NewPoolClasses(1024, 4096)
+
+	buf := p.Get()
+	buf.AppendString(strings.Repeat("a", 1<<20))
+	buf.Free()
+	for i := 0; i < 100; i++ {
+		buf = p.GetSize(4096)
+		assert.LessOrEqual(t, buf.Cap(), 4096, "Expected oversized buffer to be dropped")
+		buf.Free()
+	}
+
+	// A grown buffer is filed under the largest class it satisfies.
+	buf = p.Get()
+	buf.AppendString(strings.Repeat("a", 3000))
+	buf.Free()
+	buf = p.GetSize(2048)
+	assert.GreaterOrEqual(t, buf.Cap(), 2048)
+	buf.Free()
+}
+
+func TestPoolStats(t *testing.T) {
+	p := NewPoolClasses(1024, 4096)
+
+	a, b := p.Get(), p.GetSize(2048)
+	a.Free()
+	b.AppendString(strings.Repeat("a", 8192))
+	b.Free()
+	p.GetSize(1 << 20).Free()
+
+	s := p.Stats()
+	assert.Equal(t, int64(3), s.Gets)
+	assert.Equal(t, int64(3), s.Puts)
+	assert.Equal(t, int64(3), s.Allocs)
+	assert.Equal(t, int64(2), s.Drops)
+	assert.Equal(t, int64(1024), s.PutBytes)
+
+	p.Get().Free()
+	s = p.Stats()
+	assert.Equal(t, int64(4), s.Gets)
+	assert.Equal(t, int64(4), s.Puts)
+	assert.LessOrEqual(t, s.Allocs, int64(4))
+	assert.Equal(t, int64(2048), s.PutBytes, "Expected PutBytes to be cumulative")
+}
+
+func TestPoolReusesGrownBuffers(t *testing.T) {
+	if raceEnabled || debugEnabled {
+		t.Skip("pooled buffers are not reliably reused")
+	}
+	p := NewPool()
+	data := strings.Repeat("a", 1500)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf := p.Get()
+		buf.AppendString(data)
+		buf.Free()
+	})
+	assert.Zero(t, allocs, "Expected grown buffers to be reused by Get")
+
+	allocs = testing.AllocsPerRun(100, func() {
+		buf := p.GetSize(100)
+		buf.AppendString(data)
+		buf.Free()
+	})
+	assert.Zero(t, allocs, "Expected grown buffers to be reused by GetSize")
+}
@@ -0,0 +1,51 @@
+package jsondb
+
+import "time"
+
+// MarkDirty records that Data was modified and schedules a save. With
+// AutoSave, the save happens at the end of the current window; without
+// it, MarkDirty saves in the background right away. Errors are reported
+// by the next Flush or Close.
+func (db *DB[T]) MarkDirty() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.markDirtyLocked()
+}
+
+func (db *DB[T]) markDirtyLocked() {
+	db.dirty = true
+	if db.saveTimer == nil && !db.closed {
+		db.saveTimer = time.AfterFunc(db.opts.AutoSave, db.autoSave)
+	}
+}
+
+func (db *DB[T]) autoSave() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.saveTimer = nil
+	if !db.dirty || db.closed {
+		return
+	}
+	db.saveErr = db.saveLocked()
+}
+
+// Flush saves pending changes immediately. It returns the error from
+// saving them or, if there were none, from the last failed auto-save.
+func (db *DB[T]) Flush() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.flushLocked()
+}
+
+func (db *DB[T]) flushLocked() error {
+	if db.saveTimer != nil {
+		db.saveTimer.Stop()
+		db.saveTimer = nil
+	}
+	if db.dirty {
+		db.saveErr = db.saveLocked()
+	}
+	err := db.saveErr
+	db.saveErr = nil
+	return err
+}
@@ -0,0 +1,70 @@
+package jsondb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAutoSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	opts := Options{AutoSave: 50 * time.Millisecond}
+	db, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, cancel := db.Subscribe()
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		if err := db.Update(func(d *testDB) error { d.AnInt++; return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("database written before the auto-save window: %v", err)
+	}
+
+	ev := nextEvent(t, ch)
+	if ev.After.AnInt != 10 {
+		t.Errorf("auto-saved AnInt = %d, want 10", ev.After.AnInt)
+	}
+	select {
+	case ev := <-ch:
+		t.Errorf("unexpected second event: %+v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	db.Data.MyString = "direct"
+	db.MarkDirty()
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db2, err := Open[testDB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db2.Data.AnInt != 10 || db2.Data.MyString != "direct" {
+		t.Errorf("saved data = %+v", db2.Data)
+	}
+}
+
+func TestAutoSaveError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "db.json")
+	db, err := OpenOptions[testDB](path, Options{AutoSave: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(d *testDB) error { d.AnInt = 1; return nil }); err != nil {
+		t.Fatalf("Update with deferred write: %v", err)
+	}
+	if err := db.Flush(); err == nil {
+		t.Fatal("Flush into missing directory succeeded")
+	}
+	if err := os.Mkdir(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close after fixing directory: %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package jsondb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// A Codec converts database values to and from the bytes stored on disk.
+type Codec interface {
+	// Marshal returns the encoding of v.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data into v, which is a non-nil pointer.
+	Unmarshal(data []byte, v any) error
+	// Ext returns the file name extension conventionally used for
+	// files in this format, such as ".json".
+	Ext() string
+}
+
+// jsonFramer is implemented by codecs whose encoding is a byte-level
+// transformation of JSON. Schema versioning needs the raw JSON to run
+// migrations, so it is only available with these codecs.
+type jsonFramer interface {
+	// fromJSON converts a JSON document to the codec's encoding.
+	fromJSON(js []byte) ([]byte, error)
+	// toJSON converts the codec's encoding back to a JSON document.
+	toJSON(data []byte) ([]byte, error)
+}
+
+var (
+	// JSON encodes values as compact JSON. It is the default codec.
+	JSON Codec = jsonCodec{}
+
+	// IndentJSON encodes values as JSON indented with tabs, for files
+	// that are meant to be read or edited by hand.
+	IndentJSON Codec = jsonCodec{indent: "\t"}
+
+	// Gob encodes values with encoding/gob. It does not support schema
+	// versioning.
+	Gob Codec = gobCodec{}
+)
+
+// errNotJSON and errJournalNotJSON are returned when schema versioning
+// or the journal is used with a codec that is not based on JSON.
+var (
+	errNotJSON        = errors.New("jsondb: schema versioning requires a JSON-based codec")
+	errJournalNotJSON = errors.New("jsondb: Journal requires a JSON-based codec")
+)
+
+type jsonCodec struct {
+	indent string
+}
+
+func (c jsonCodec) Marshal(v any) ([]byte, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.fromJSON(bs)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Ext() string { return ".json" }
+
+func (c jsonCodec) fromJSON(js []byte) ([]byte, error) {
+	if c.indent == "" {
+		return js, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, js, "", c.indent); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func (jsonCodec) toJSON(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (gobCodec) Ext() string { return ".gob" }
+
+// Gzip returns a codec that compresses the output of c with gzip.
+func Gzip(c Codec) Codec {
+	return compressCodec{
+		Codec: c,
+		ext:   ".gz",
+		compress: func(data []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			if _, err := zw.Write(data); err != nil {
+				return nil, err
+			}
+			if err := zw.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		},
+		decompress: func(data []byte) ([]byte, error) {
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			return io.ReadAll(zr)
+		},
+	}
+}
+
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	})
+)
+
+// Zstd returns a codec that compresses the output of c with zstd.
+func Zstd(c Codec) Codec {
+	return compressCodec{
+		Codec: c,
+		ext:   ".zst",
+		compress: func(data []byte) ([]byte, error) {
+			enc, err := zstdEncoder()
+			if err != nil {
+				return nil, err
+			}
+			return enc.EncodeAll(data, nil), nil
+		},
+		decompress: func(data []byte) ([]byte, error) {
+			dec, err := zstdDecoder()
+			if err != nil {
+				return nil, err
+			}
+			return dec.DecodeAll(data, nil)
+		},
+	}
+}
+
+// compressCodec wraps a Codec with a compression format.
+type compressCodec struct {
+	Codec
+	ext        string
+	compress   func([]byte) ([]byte, error)
+	decompress func([]byte) ([]byte, error)
+}
+
+func (c compressCodec) Marshal(v any) ([]byte, error) {
+	bs, err := c.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return c.compress(bs)
+}
+
+func (c compressCodec) Unmarshal(data []byte, v any) error {
+	bs, err := c.decompress(data)
+	if err != nil {
+		return err
+	}
+	return c.Codec.Unmarshal(bs, v)
+}
+
+func (c compressCodec) Ext() string { return c.Codec.Ext() + c.ext }
+
+func (c compressCodec) fromJSON(js []byte) ([]byte, error) {
+	f, ok := c.Codec.(jsonFramer)
+	if !ok {
+		return nil, errNotJSON
+	}
+	bs, err := f.fromJSON(js)
+	if err != nil {
+		return nil, err
+	}
+	return c.compress(bs)
+}
+
+func (c compressCodec) toJSON(data []byte) ([]byte, error) {
+	f, ok := c.Codec.(jsonFramer)
+	if !ok {
+		return nil, errNotJSON
+	}
+	bs, err := c.decompress(data)
+	if err != nil {
+		return nil, err
+	}
+	return f.toJSON(bs)
+}
+
+// uncompressed returns c without any compression wrappers.
+func uncompressed(c Codec) Codec {
+	for {
+		cc, ok := c.(compressCodec)
+		if !ok {
+			return c
+		}
+		c = cc.Codec
+	}
+}
+
+// isJSON reports whether c is a JSON-based codec.
+func isJSON(c Codec) bool {
+	_, ok := uncompressed(c).(jsonCodec)
+	return ok
+}
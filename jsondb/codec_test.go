@@ -0,0 +1,87 @@
+package jsondb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCodecs(t *testing.T) {
+	codecs := map[string]Codec{
+		"JSON":       JSON,
+		"IndentJSON": IndentJSON,
+		"Gob":        Gob,
+		"GzipJSON":   Gzip(JSON),
+		"ZstdJSON":   Zstd(JSON),
+		"ZstdGob":    Zstd(Gob),
+	}
+	for name, c := range codecs {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "db"+c.Ext())
+			opts := Options{Codec: c}
+			db, err := OpenOptions[testDB](path, opts)
+			if err != nil {
+				t.Fatalf("creating empty DB: %v", err)
+			}
+			err = db.Update(func(d *testDB) error {
+				d.MyString = "test"
+				d.AnInt = 42
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+
+			db2, err := OpenOptions[testDB](path, opts)
+			if err != nil {
+				t.Fatalf("opening DB again: %v", err)
+			}
+			want := &testDB{MyString: "test", AnInt: 42}
+			if diff := cmp.Diff(db2.Data, want, cmp.AllowUnexported(testDB{})); diff != "" {
+				t.Fatalf("unexpected saved DB content (-got+want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCodecExt(t *testing.T) {
+	if got, want := Gzip(IndentJSON).Ext(), ".json.gz"; got != want {
+		t.Errorf("Gzip(IndentJSON).Ext() = %q, want %q", got, want)
+	}
+	if got, want := Zstd(Gob).Ext(), ".gob.zst"; got != want {
+		t.Errorf("Zstd(Gob).Ext() = %q, want %q", got, want)
+	}
+}
+
+func TestVersionedCodec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json.gz")
+	opts := Options{Codec: Gzip(IndentJSON)}
+	db, err := OpenOptions[migratedDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Data.Name = "web"
+	if err := db.Save(); err != nil {
+		t.Fatal(err)
+	}
+	db2, err := OpenOptions[migratedDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db2.Data.Name != "web" {
+		t.Errorf("Name = %q, want %q", db2.Data.Name, "web")
+	}
+
+	if _, err := OpenOptions[migratedDB](path, Options{Codec: Gob}); err != errNotJSON {
+		t.Errorf("opening versioned DB with Gob = %v, want %v", err, errNotJSON)
+	}
+}
+
+func TestJournalRequiresJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.gob")
+	_, err := OpenOptions[testDB](path, Options{Codec: Gob, Journal: true})
+	if err == nil || err.Error() != "jsondb: Journal requires a JSON-based codec" {
+		t.Errorf("opening journaled DB with Gob = %v, want the Journal error", err)
+	}
+}
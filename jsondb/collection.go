@@ -0,0 +1,272 @@
+package jsondb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// errNoChange abandons a collection update that would not change
+// anything.
+var errNoChange = errors.New("jsondb: no change")
+
+// A Record is a key-value pair stored in a Collection.
+type Record[K comparable, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+}
+
+// A Collection is a set of records stored in a DB, with lookups by key
+// and by secondary indexes. The records are stored as a JSON array, so
+// K may be any comparable type that encodes to JSON.
+//
+// Values returned by a Collection share memory with its contents and
+// must not be modified; use Put to change a record.
+type Collection[K comparable, V any] struct {
+	db *DB[[]Record[K, V]]
+
+	mu      sync.Mutex
+	indexes map[string]func(V) any
+
+	// built is the contents pos and idx were computed from. They are
+	// rebuilt when the DB's contents are replaced by other means, such
+	// as a reload.
+	built *[]Record[K, V]
+	pos   map[K]int
+	idx   map[string]map[any]map[K]struct{}
+}
+
+// OpenCollection opens the collection stored at path.
+func OpenCollection[K comparable, V any](path string, opts Options) (*Collection[K, V], error) {
+	db, err := OpenOptions[[]Record[K, V]](path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Collection[K, V]{
+		db:      db,
+		indexes: make(map[string]func(V) any),
+	}, nil
+}
+
+// DB returns the DB holding the collection.
+func (c *Collection[K, V]) DB() *DB[[]Record[K, V]] {
+	return c.db
+}
+
+// Close closes the underlying DB.
+func (c *Collection[K, V]) Close() error {
+	return c.db.Close()
+}
+
+// AddIndex declares a secondary index named name. fn extracts the
+// indexed value from a record's value; it must return a comparable
+// value, or nil to leave the record out of the index.
+func (c *Collection[K, V]) AddIndex(name string, fn func(V) any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexes[name] = fn
+	c.built = nil
+}
+
+// view calls fn with the current records while holding the DB's read
+// lock, after bringing the indexes up to date. c.mu must be held.
+func (c *Collection[K, V]) view(fn func(recs []Record[K, V])) {
+	c.db.View(func(p *[]Record[K, V]) error {
+		if p != c.built {
+			c.rebuild(p)
+		}
+		fn(*p)
+		return nil
+	})
+}
+
+func (c *Collection[K, V]) rebuild(p *[]Record[K, V]) {
+	c.built = p
+	c.pos = make(map[K]int, len(*p))
+	c.idx = make(map[string]map[any]map[K]struct{}, len(c.indexes))
+	for name := range c.indexes {
+		c.idx[name] = make(map[any]map[K]struct{})
+	}
+	for i, r := range *p {
+		c.pos[r.Key] = i
+		c.indexAdd(r)
+	}
+}
+
+func (c *Collection[K, V]) indexAdd(r Record[K, V]) {
+	for name, fn := range c.indexes {
+		v := fn(r.Value)
+		if v == nil {
+			continue
+		}
+		keys := c.idx[name][v]
+		if keys == nil {
+			keys = make(map[K]struct{})
+			c.idx[name][v] = keys
+		}
+		keys[r.Key] = struct{}{}
+	}
+}
+
+func (c *Collection[K, V]) indexRemove(r Record[K, V]) {
+	for name, fn := range c.indexes {
+		v := fn(r.Value)
+		if v == nil {
+			continue
+		}
+		delete(c.idx[name][v], r.Key)
+		if len(c.idx[name][v]) == 0 {
+			delete(c.idx[name], v)
+		}
+	}
+}
+
+// Get returns the value stored under k.
+func (c *Collection[K, V]) Get(k K) (v V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.view(func(recs []Record[K, V]) {
+		var i int
+		if i, ok = c.pos[k]; ok {
+			v = recs[i].Value
+		}
+	})
+	return v, ok
+}
+
+// Len returns the number of records in the collection.
+func (c *Collection[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int
+	c.view(func(recs []Record[K, V]) { n = len(recs) })
+	return n
+}
+
+// Range calls fn for each record until fn returns false. It iterates
+// over the records as they were when Range was called and holds no lock
+// while calling fn, so fn may use the collection, including to modify
+// it; such changes are not seen by the ongoing Range.
+func (c *Collection[K, V]) Range(fn func(K, V) bool) {
+	c.mu.Lock()
+	var recs []Record[K, V]
+	c.view(func(cur []Record[K, V]) {
+		recs = append(recs, cur...)
+	})
+	c.mu.Unlock()
+	for _, r := range recs {
+		if !fn(r.Key, r.Value) {
+			return
+		}
+	}
+}
+
+// Query returns the records whose value for the index name equals
+// value, in no particular order.
+func (c *Collection[K, V]) Query(name string, value any) ([]Record[K, V], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.indexes[name]; !ok {
+		return nil, fmt.Errorf("jsondb: unknown index %q", name)
+	}
+	var out []Record[K, V]
+	c.view(func(recs []Record[K, V]) {
+		for k := range c.idx[name][value] {
+			out = append(out, recs[c.pos[k]])
+		}
+	})
+	return out, nil
+}
+
+// QueryKeys is like Query but returns only the keys of the matching
+// records.
+func (c *Collection[K, V]) QueryKeys(name string, value any) ([]K, error) {
+	recs, err := c.Query(name, value)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]K, len(recs))
+	for i, r := range recs {
+		keys[i] = r.Key
+	}
+	return keys, nil
+}
+
+// Put stores v under k, replacing any existing value, and saves the
+// collection.
+func (c *Collection[K, V]) Put(k K, v V) error {
+	return c.modify(func(recs *[]Record[K, V], pos map[K]int) (func(), error) {
+		r := Record[K, V]{Key: k, Value: v}
+		if i, ok := pos[k]; ok {
+			old := (*recs)[i]
+			(*recs)[i] = r
+			return func() {
+				c.indexRemove(old)
+				c.indexAdd(r)
+			}, nil
+		}
+		*recs = append(*recs, r)
+		return func() {
+			c.pos[k] = len(*recs) - 1
+			c.indexAdd(r)
+		}, nil
+	})
+}
+
+// Delete removes the record stored under k, if any, and saves the
+// collection.
+func (c *Collection[K, V]) Delete(k K) error {
+	return c.modify(func(recs *[]Record[K, V], pos map[K]int) (func(), error) {
+		i, ok := pos[k]
+		if !ok {
+			return nil, nil
+		}
+		// Move the last record into the hole rather than shifting the
+		// rest, so only one position changes.
+		old, last := (*recs)[i], len(*recs)-1
+		(*recs)[i] = (*recs)[last]
+		*recs = (*recs)[:last]
+		return func() {
+			c.indexRemove(old)
+			delete(c.pos, k)
+			if i < last {
+				c.pos[(*recs)[i].Key] = i
+			}
+		}, nil
+	})
+}
+
+// modify runs fn in a DB update. fn edits next, a copy of the records
+// whose positions are given by pos, and returns a function that updates
+// the indexes if the update commits. A nil function means nothing
+// changed and the update is abandoned.
+func (c *Collection[K, V]) modify(fn func(next *[]Record[K, V], pos map[K]int) (func(), error)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var (
+		commit    func()
+		committed *[]Record[K, V]
+	)
+	err := c.db.update(func(cur, next *[]Record[K, V]) error {
+		if cur != c.built {
+			c.rebuild(cur)
+		}
+		var err error
+		if commit, err = fn(next, c.pos); err != nil {
+			return err
+		}
+		if commit == nil {
+			return errNoChange
+		}
+		committed = next
+		return nil
+	})
+	if err == errNoChange {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	commit()
+	c.built = committed
+	return nil
+}
@@ -0,0 +1,129 @@
+package jsondb
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type user struct {
+	Name string
+	Team string
+	Age  int
+}
+
+func TestCollection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	c, err := OpenCollection[int, user](path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AddIndex("team", func(u user) any { return u.Team })
+	c.AddIndex("adult", func(u user) any {
+		if u.Age < 18 {
+			return nil
+		}
+		return true
+	})
+
+	users := []user{
+		{"alice", "red", 30},
+		{"bob", "blue", 17},
+		{"carol", "red", 25},
+		{"dave", "blue", 40},
+	}
+	for i, u := range users {
+		if err := c.Put(i+1, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queryKeys := func(c *Collection[int, user], index string, value any) []int {
+		t.Helper()
+		keys, err := c.QueryKeys(index, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Ints(keys)
+		return keys
+	}
+	if diff := cmp.Diff(queryKeys(c, "team", "red"), []int{1, 3}); diff != "" {
+		t.Errorf("team=red (-got+want):\n%s", diff)
+	}
+	if diff := cmp.Diff(queryKeys(c, "adult", true), []int{1, 3, 4}); diff != "" {
+		t.Errorf("adult (-got+want):\n%s", diff)
+	}
+
+	if err := c.Put(3, user{"carol", "blue", 25}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(42); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(queryKeys(c, "team", "blue"), []int{2, 3, 4}); diff != "" {
+		t.Errorf("team=blue after update (-got+want):\n%s", diff)
+	}
+	if _, ok := c.Get(1); ok {
+		t.Error("Get(1) found deleted record")
+	}
+	if u, ok := c.Get(4); !ok || u.Name != "dave" {
+		t.Errorf("Get(4) = %v, %v", u, ok)
+	}
+	if _, err := c.Query("missing", 1); err == nil {
+		t.Error("Query on unknown index succeeded")
+	}
+
+	c2, err := OpenCollection[int, user](path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.AddIndex("team", func(u user) any { return u.Team })
+	if c2.Len() != 3 {
+		t.Errorf("Len after reopen = %d, want 3", c2.Len())
+	}
+	if diff := cmp.Diff(queryKeys(c2, "team", "blue"), []int{2, 3, 4}); diff != "" {
+		t.Errorf("team=blue after reopen (-got+want):\n%s", diff)
+	}
+	var names []string
+	c2.Range(func(_ int, u user) bool {
+		names = append(names, u.Name)
+		return true
+	})
+	sort.Strings(names)
+	if diff := cmp.Diff(names, []string{"bob", "carol", "dave"}); diff != "" {
+		t.Errorf("Range (-got+want):\n%s", diff)
+	}
+
+	// fn may call back into the collection, even to modify it.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c2.Range(func(k int, u user) bool {
+			if _, ok := c2.Get(k); !ok {
+				t.Errorf("Get(%d) in Range: not found", k)
+			}
+			c2.Len()
+			if _, err := c2.Query("team", u.Team); err != nil {
+				t.Errorf("Query in Range: %v", err)
+			}
+			if err := c2.Delete(k); err != nil {
+				t.Errorf("Delete in Range: %v", err)
+			}
+			return true
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Range deadlocked calling back into the collection")
+	}
+	if c2.Len() != 0 {
+		t.Errorf("Len after deleting in Range = %d, want 0", c2.Len())
+	}
+}
@@ -0,0 +1,224 @@
+package jsondb
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A KeyProvider supplies the keys used to encrypt database files. Keys
+// are 16, 24 or 32 bytes long, selecting AES-128, AES-192 or AES-256.
+type KeyProvider interface {
+	// CurrentKey returns the key new writes are sealed with and its ID.
+	// The ID is stored in the clear and must be at most 255 bytes.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key with the given ID, to open files sealed with
+	// an earlier key.
+	Key(id string) ([]byte, error)
+}
+
+var (
+	// ErrKeyNotFound is returned when a file is sealed with a key the
+	// KeyProvider does not know.
+	ErrKeyNotFound = errors.New("jsondb: encryption key not found")
+
+	// ErrDecrypt is returned when a sealed file fails authentication,
+	// because it was sealed with a different key or was tampered with.
+	ErrDecrypt = errors.New("jsondb: decryption failed")
+)
+
+// StaticKey returns a KeyProvider for a single key, identified by a
+// prefix of its SHA-256 hash.
+func StaticKey(key []byte) KeyProvider {
+	sum := sha256.Sum256(key)
+	return staticKey{id: hex.EncodeToString(sum[:8]), key: key}
+}
+
+type staticKey struct {
+	id  string
+	key []byte
+}
+
+func (k staticKey) CurrentKey() (string, []byte, error) {
+	return k.id, k.key, nil
+}
+
+func (k staticKey) Key(id string) ([]byte, error) {
+	if id != k.id {
+		return nil, ErrKeyNotFound
+	}
+	return k.key, nil
+}
+
+// rotatedKey seals with a new key and falls back to the previous
+// provider to open files sealed before a Rekey.
+type rotatedKey struct {
+	KeyProvider
+	old KeyProvider
+}
+
+func (k rotatedKey) Key(id string) ([]byte, error) {
+	key, err := k.KeyProvider.Key(id)
+	if errors.Is(err, ErrKeyNotFound) && k.old != nil {
+		return k.old.Key(id)
+	}
+	return key, err
+}
+
+// A sealed file starts with sealMagic, a format version byte, the
+// length of the key ID and the key ID, followed by the AES-GCM nonce and
+// ciphertext. The header up to the nonce is authenticated as additional
+// data.
+var sealMagic = []byte("JDBE")
+
+const sealVersion = 1
+
+// seal encrypts data with the current key of kp.
+func seal(kp KeyProvider, data []byte) ([]byte, error) {
+	id, key, err := kp.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("jsondb: key ID %q too long", id)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	hdr := make([]byte, 0, len(sealMagic)+2+len(id)+aead.NonceSize())
+	hdr = append(hdr, sealMagic...)
+	hdr = append(hdr, sealVersion, byte(len(id)))
+	hdr = append(hdr, id...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append(hdr, nonce...)
+	return aead.Seal(out, nonce, data, hdr), nil
+}
+
+// unseal decrypts data sealed by seal.
+func unseal(kp KeyProvider, data []byte) ([]byte, error) {
+	n := len(sealMagic)
+	if !bytes.HasPrefix(data, sealMagic) || len(data) < n+2 || data[n] != sealVersion || len(data) < n+2+int(data[n+1]) {
+		return nil, ErrDecrypt
+	}
+	hdr := data[:n+2+int(data[n+1])]
+	id := string(hdr[n+2:])
+	key, err := kp.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	rest := data[len(hdr):]
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, hdr)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts data if the DB has a key.
+func (db *DB[T]) seal(data []byte) ([]byte, error) {
+	if db.keys == nil {
+		return data, nil
+	}
+	return seal(db.keys, data)
+}
+
+// errUnsealed is returned for an unencrypted file of an encrypted
+// database, unless Options.AllowPlaintext is set.
+var errUnsealed = fmt.Errorf("jsondb: unencrypted file of encrypted database: %w", ErrDecrypt)
+
+// unseal decrypts data with the keys of the DB.
+func (db *DB[T]) unseal(data []byte) ([]byte, error) {
+	return db.unsealWith(db.keys, data)
+}
+
+// unsealWith decrypts data with kp. Sealed data without a key is an
+// error, and so is plain data with a key, since anyone able to write the
+// file could inject it.
+func (db *DB[T]) unsealWith(kp KeyProvider, data []byte) ([]byte, error) {
+	sealed := bytes.HasPrefix(data, sealMagic)
+	switch {
+	case kp == nil && sealed:
+		return nil, ErrKeyNotFound
+	case kp == nil || !sealed && db.opts.AllowPlaintext:
+		return data, nil
+	case !sealed:
+		return nil, errUnsealed
+	}
+	return unseal(kp, data)
+}
+
+// Rekey re-encrypts the database and its snapshots with newKey, which
+// is used for all further writes. Files sealed with the previous key can
+// still be opened by this DB, but other DBs need a KeyProvider that
+// knows newKey. Rekey also encrypts a database, and its snapshots, that
+// was not encrypted before.
+func (db *DB[T]) Rekey(newKey []byte) error {
+	if _, err := newAEAD(newKey); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	unlock, err := db.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := db.reloadLocked(); err != nil {
+		return err
+	}
+
+	old := db.keys
+	db.keys = rotatedKey{KeyProvider: StaticKey(newKey), old: old}
+	if err := db.compact(db.Data); err != nil {
+		db.keys = old
+		return err
+	}
+	snaps, err := db.snapshots()
+	if err != nil {
+		return err
+	}
+	for _, s := range snaps {
+		name := db.snapshotPath(s.ID)
+		bs, err := db.st.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if bs, err = db.unsealWith(old, bs); err != nil {
+			return fmt.Errorf("jsondb: reading snapshot %s: %w", s.ID, err)
+		}
+		if bs, err = db.seal(bs); err != nil {
+			return err
+		}
+		if err := db.st.WriteFile(name, bs, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
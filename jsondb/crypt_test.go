@@ -0,0 +1,222 @@
+package jsondb
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	key := bytes.Repeat([]byte{1}, 32)
+	opts := Options{Key: key, Journal: true, Backups: 2}
+	db, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"secret-token", "other-secret"} {
+		if err := db.Update(func(d *testDB) error { d.MyString = s; return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".wal"} {
+		bs, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(bs, []byte("secret")) {
+			t.Errorf("%s contains plaintext: %q", name, bs)
+		}
+	}
+
+	db2, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db2.Data.MyString != "other-secret" {
+		t.Errorf("MyString = %q, want %q", db2.Data.MyString, "other-secret")
+	}
+
+	if _, err := Open[testDB](path); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("opening without key = %v, want ErrKeyNotFound", err)
+	}
+	wrong := StaticKey(bytes.Repeat([]byte{2}, 32))
+	if _, err := OpenOptions[testDB](path, Options{KeyProvider: rotatedKey{wrong, StaticKey(key)}}); err != nil {
+		t.Errorf("opening with previous key available: %v", err)
+	}
+
+	newKey := bytes.Repeat([]byte{3}, 32)
+	if err := db2.Rekey(newKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenOptions[testDB](path, opts); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("opening with old key after Rekey = %v, want ErrKeyNotFound", err)
+	}
+	db3, err := OpenOptions[testDB](path, Options{Key: newKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db3.Data.MyString != "other-secret" {
+		t.Errorf("MyString after Rekey = %q, want %q", db3.Data.MyString, "other-secret")
+	}
+	snaps, err := db3.Snapshots()
+	if err != nil || len(snaps) == 0 {
+		t.Fatalf("Snapshots = %v, %v", snaps, err)
+	}
+	if err := db3.RestoreSnapshot(snaps[len(snaps)-1].ID); err != nil {
+		t.Errorf("restoring resealed snapshot: %v", err)
+	}
+}
+
+func TestSealTamper(t *testing.T) {
+	kp := StaticKey(bytes.Repeat([]byte{1}, 16))
+	sealed, err := seal(kp, []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := unseal(kp, sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("unseal(tampered) = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestEncryptedJournalInjection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	opts := Options{Key: bytes.Repeat([]byte{1}, 32), Journal: true}
+	db, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(d *testDB) error { d.MyString = "safe"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	main, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal := `{"base":"` + checksum(main) + `"}` + "\n" +
+		`{"time":"2024-01-01T00:00:00Z","patch":[{"op":"replace","path":"/MyString","value":"pwned"}]}` + "\n"
+	if err := os.WriteFile(path+".wal", []byte(wal), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenOptions[testDB](path, opts); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("opening with plaintext journal = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestEncryptedPlaintextFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	if err := os.WriteFile(path, []byte(`{"MyString":"pwned"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Key: bytes.Repeat([]byte{1}, 32)}
+	if _, err := OpenOptions[testDB](path, opts); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("opening plaintext file with key = %v, want ErrDecrypt", err)
+	}
+
+	// AllowPlaintext migrates the file to encryption on its next write.
+	migrate := opts
+	migrate.AllowPlaintext = true
+	db, err := OpenOptions[testDB](path, migrate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(); err != nil {
+		t.Fatal(err)
+	}
+	db2, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatalf("opening migrated file: %v", err)
+	}
+	if db2.Data.MyString != "pwned" {
+		t.Errorf("MyString = %q, want %q", db2.Data.MyString, "pwned")
+	}
+}
+
+func TestRekeyPlaintext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	db, err := OpenOptions[testDB](path, Options{Backups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if err := db.Update(func(d *testDB) error { d.AnInt = int64(i); return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := bytes.Repeat([]byte{1}, 32)
+	if err := db.Rekey(key); err != nil {
+		t.Fatal(err)
+	}
+
+	db2, err := OpenOptions[testDB](path, Options{Key: key, Backups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snaps, err := db2.Snapshots()
+	if err != nil || len(snaps) != 1 {
+		t.Fatalf("Snapshots = %v, %v; want 1 snapshot", snaps, err)
+	}
+	if err := db2.RestoreSnapshot(snaps[0].ID); err != nil {
+		t.Fatalf("restoring encrypted snapshot: %v", err)
+	}
+	if db2.Data.AnInt != 1 {
+		t.Errorf("AnInt after restore = %d, want 1", db2.Data.AnInt)
+	}
+}
+
+// failingStorage fails the writes ("write") and removals ("remove") of
+// files for which fail reports true.
+type failingStorage struct {
+	Storage
+	fail func(op, name string) bool
+}
+
+func (s *failingStorage) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if s.fail != nil && s.fail("write", name) {
+		return errors.New("write failed")
+	}
+	return s.Storage.WriteFile(name, data, perm)
+}
+
+func (s *failingStorage) Remove(name string) error {
+	if s.fail != nil && s.fail("remove", name) {
+		return errors.New("remove failed")
+	}
+	return s.Storage.Remove(name)
+}
+
+func TestRekeyFailureKeepsKey(t *testing.T) {
+	st := &failingStorage{Storage: NewMemStorage()}
+	key := bytes.Repeat([]byte{1}, 32)
+	opts := Options{Storage: st, Key: key}
+	db, err := OpenOptions[testDB]("db.json", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(d *testDB) error { d.MyString = "a"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	st.fail = func(string, string) bool { return true }
+	if err := db.Rekey(bytes.Repeat([]byte{2}, 32)); err == nil {
+		t.Fatal("Rekey succeeded with failing storage")
+	}
+	st.fail = nil
+	if err := db.Update(func(d *testDB) error { d.MyString = "b"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	db2, err := OpenOptions[testDB]("db.json", opts)
+	if err != nil {
+		t.Fatalf("opening with the original key after failed Rekey: %v", err)
+	}
+	if db2.Data.MyString != "b" {
+		t.Errorf("MyString = %q, want %q", db2.Data.MyString, "b")
+	}
+}
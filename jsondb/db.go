@@ -8,48 +8,609 @@ package jsondb
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
+	"sync"
+	"time"
 )
 
+// Options configures a DB opened with OpenOptions.
+type Options struct {
+	// Storage holds the database files. The default is OSStorage.
+	// Lock and Watch require OSStorage.
+	Storage Storage
+
+	// Lock selects the advisory lock taken on a sidecar ".lock" file
+	// so that several processes can share one database file. The zero
+	// value, LockNone, assumes a single process owns the file.
+	Lock LockMode
+
+	// LockTimeout bounds how long an operation waits for the advisory
+	// lock before failing with ErrLockTimeout. Zero waits forever.
+	LockTimeout time.Duration
+
+	// Codec encodes the database file. The default is JSON.
+	Codec Codec
+
+	// Journal makes Save and Update append each change as a JSON Patch
+	// record to a ".wal" journal next to the database file instead of
+	// rewriting the whole file. Open replays the journal, and it is
+	// compacted into the main file once it grows past JournalMaxSize
+	// or JournalMaxAge. Journaling requires a JSON-based codec.
+	Journal bool
+
+	// JournalMaxSize is the journal size in bytes that triggers
+	// compaction. The default is 1 MiB.
+	JournalMaxSize int64
+
+	// JournalMaxAge is the age of the oldest journal record that
+	// triggers compaction on the next write. Zero means no limit.
+	JournalMaxAge time.Duration
+
+	// Key encrypts the database file, its journal and its snapshots with
+	// AES-GCM. It must be 16, 24 or 32 bytes long. Unencrypted files are
+	// refused with an error matching ErrDecrypt unless AllowPlaintext is
+	// set.
+	Key []byte
+
+	// KeyProvider supplies encryption keys by ID, for deployments that
+	// rotate keys. It takes precedence over Key.
+	KeyProvider KeyProvider
+
+	// AllowPlaintext lets an encrypted DB open unencrypted files, which
+	// are encrypted on their next write. It is meant for migrating an
+	// existing database to encryption: anyone able to write the files
+	// can then inject data.
+	AllowPlaintext bool
+
+	// Schema is a JSON Schema document the contents of the database
+	// must satisfy when opened and before every write. Violations, like
+	// those reported by a T that implements Validator, are returned as
+	// a *ValidationError. Only a subset of JSON Schema is supported; see
+	// the schema type for details.
+	Schema []byte
+
+	// Checksum keeps a SHA-256 checksum of the database file in a
+	// ".sum" file next to it and makes Open fail with ErrCorrupt if
+	// they do not match. The sidecar must be removed after editing the
+	// file by hand.
+	Checksum bool
+
+	// Recover makes Open replace a corrupt database file with the most
+	// recent readable temporary file left by an interrupted write or
+	// snapshot, instead of failing with ErrCorrupt. The corrupt file is
+	// kept with a ".corrupt" suffix. Since recovery writes, Open and
+	// Reload take the exclusive lock when Recover is set.
+	Recover bool
+
+	// Backups is the number of snapshots of the database to keep next
+	// to it. Save and Update take a snapshot of the data they are about
+	// to replace, at most once per BackupInterval. Zero disables
+	// snapshots.
+	Backups int
+
+	// BackupInterval is the minimum time between two snapshots.
+	BackupInterval time.Duration
+
+	// AutoSave defers writes: Update only changes the in-memory
+	// contents, and callers that modify Data directly call MarkDirty.
+	// A background goroutine then saves the database once per AutoSave
+	// window, coalescing all changes made in the meantime. Flush and
+	// Close save pending changes. AutoSave cannot be combined with Lock
+	// or Watch, whose reloads would discard unsaved changes.
+	AutoSave time.Duration
+
+	// Watch starts a goroutine that reloads the database whenever the
+	// file is modified outside this DB, for instance by another
+	// process or by hand, and sends an EventReload to subscribers. It
+	// uses inotify on Linux and polls elsewhere. Call Close to stop it.
+	Watch bool
+}
+
 // DB is a database backed by a JSON file.
 type DB[T any] struct {
 	// Data is the contents of the database.
+	//
+	// Direct access to Data is not synchronized. Callers sharing a DB
+	// between goroutines should use View and Update instead.
 	Data *T
 
-	path string
+	path       string
+	opts       Options
+	st         Storage
+	codec      Codec
+	keys       KeyProvider
+	schema     *schema
+	migrations []migration
+	version    int // current schema version
+	mu         sync.RWMutex
+	fi         fs.FileInfo // file as of the last read or write, nil if absent
+	j          journal
+	lastSnap   time.Time
+	notifier   notifier[T]
+	closed     bool
+	watcher    watcher
+	watchDone  chan struct{}
+	dirty      bool        // in-memory contents not yet saved
+	saveTimer  *time.Timer // pending auto-save
+	saveErr    error       // error from the last auto-save
 }
 
 // Open opens the database at path, creating it with a zero value if
 // necessary.
 func Open[T any](path string) (*DB[T], error) {
-	bs, err := os.ReadFile(path)
-	if errors.Is(err, fs.ErrNotExist) {
-		return &DB[T]{
-			Data: new(T),
-			path: path,
-		}, nil
-	} else if err != nil {
+	return OpenOptions[T](path, Options{})
+}
+
+// OpenOptions is like Open but configures the DB with opts.
+func OpenOptions[T any](path string, opts Options) (*DB[T], error) {
+	db := &DB[T]{
+		path:  path,
+		opts:  opts,
+		st:    opts.Storage,
+		codec: opts.Codec,
+	}
+	if db.st == nil {
+		db.st = OSStorage()
+	}
+	if _, ok := db.st.(osStorage); !ok && (opts.Lock != LockNone || opts.Watch) {
+		return nil, errors.New("jsondb: Lock and Watch require OSStorage")
+	}
+	if db.codec == nil {
+		db.codec = JSON
+	}
+	switch {
+	case opts.KeyProvider != nil:
+		db.keys = opts.KeyProvider
+	case opts.Key != nil:
+		if _, err := newAEAD(opts.Key); err != nil {
+			return nil, err
+		}
+		db.keys = StaticKey(opts.Key)
+	}
+	if opts.Schema != nil {
+		var err error
+		if db.schema, err = compileSchema(opts.Schema); err != nil {
+			return nil, err
+		}
+	}
+	db.migrations, db.version = migrationsFor[T]()
+	if db.version > 0 && !isJSON(db.codec) {
+		return nil, errNotJSON
+	}
+	if opts.Journal && !isJSON(db.codec) {
+		return nil, errJournalNotJSON
+	}
+	if opts.AutoSave > 0 && (opts.Lock != LockNone || opts.Watch) {
+		return nil, errors.New("jsondb: AutoSave cannot be combined with Lock or Watch")
+	}
+	unlock, err := db.lockFile(db.readMayWrite())
+	if err != nil {
 		return nil, err
 	}
-
-	var val T
-	if err := json.Unmarshal(bs, &val); err != nil {
+	defer unlock()
+	if err := db.read(); err != nil {
 		return nil, err
 	}
-
-	return &DB[T]{
-		Data: &val,
-		path: path,
-	}, nil
+	if opts.Watch {
+		if db.watcher, err = newWatcher(path); err != nil {
+			return nil, err
+		}
+		db.watchDone = make(chan struct{})
+		go db.watch(db.watcher)
+	}
+	return db, nil
 }
 
 // Save writes db.Data back to disk.
+//
+// Save overwrites whatever is on disk, including changes made by other
+// processes since the database was last read. Use Update for
+// read-modify-write cycles on a shared file.
 func (db *DB[T]) Save() error {
-	bs, err := json.Marshal(db.Data)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.saveLocked()
+}
+
+// saveLocked writes db.Data to disk. db.mu must be held.
+func (db *DB[T]) saveLocked() error {
+	unlock, err := db.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := db.write(db.Data); err != nil {
+		return err
+	}
+	db.dirty = false
+	db.notify(EventCommit, nil)
+	return nil
+}
+
+// Close saves pending changes if AutoSave is enabled, stops the file
+// watcher, if any, and closes all subscriber channels. It returns the
+// error from the last save attempt, if it failed. The DB must not be
+// used after Close.
+func (db *DB[T]) Close() error {
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return nil
+	}
+	err := db.flushLocked()
+	db.closed = true
+	w := db.watcher
+	db.mu.Unlock()
+
+	if w != nil {
+		if werr := w.close(); err == nil {
+			err = werr
+		}
+		<-db.watchDone
+	}
+	n := &db.notifier
+	n.mu.Lock()
+	n.closeLocked()
+	n.mu.Unlock()
+	return err
+}
+
+// Reload re-reads the database file if it has been replaced or modified
+// since it was last read or written, discarding the in-memory contents.
+func (db *DB[T]) Reload() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	unlock, err := db.lockFile(db.readMayWrite())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return db.reloadLocked()
+}
+
+// View calls fn with the contents of the database while holding a read
+// lock. fn must not modify the value or retain it after returning.
+func (db *DB[T]) View(fn func(*T) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return fn(db.Data)
+}
+
+// Update calls fn with a copy of the contents of the database while
+// holding the write lock. If fn returns nil, the copy is written to disk
+// and becomes the new contents of the database. If fn or the write
+// fails, the in-memory contents are left as they were. With AutoSave,
+// the write is deferred to the next auto-save.
+//
+// The copy is made by encoding and decoding the contents with the codec,
+// so fields the codec does not persist, such as unexported fields or
+// fields tagged `json:"-"`, are zero in the copy and are cleared from
+// the database once Update succeeds.
+//
+// When the DB was opened with a LockMode other than LockNone, Update
+// holds the exclusive file lock for the whole cycle and first reloads
+// any changes made by other processes, so concurrent updates from
+// several processes are not lost.
+func (db *DB[T]) Update(fn func(*T) error) error {
+	return db.update(func(_, next *T) error { return fn(next) })
+}
+
+// update is like Update but also passes fn the current contents, which
+// are replaced by next if the update commits.
+func (db *DB[T]) update(fn func(cur, next *T) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	unlock, err := db.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if db.opts.Lock != LockNone {
+		if err := db.reloadLocked(); err != nil {
+			return err
+		}
+	}
+
+	val, err := db.clone(db.Data)
+	if err != nil {
+		return err
+	}
+	if err := fn(db.Data, val); err != nil {
+		return err
+	}
+	if db.opts.AutoSave > 0 {
+		if err := db.validate(val, nil); err != nil {
+			return err
+		}
+		db.Data = val
+		db.markDirtyLocked()
+		return nil
+	}
+	if err := db.write(val); err != nil {
+		return err
+	}
+	db.Data = val
+	db.notify(EventCommit, nil)
+	return nil
+}
+
+// reloadLocked re-reads the file if it changed on disk. db.mu must be
+// held.
+func (db *DB[T]) reloadLocked() error {
+	if db.unchanged() {
+		return nil
+	}
+	if err := db.read(); err != nil {
+		return err
+	}
+	db.notify(EventReload, nil)
+	return nil
+}
+
+// unchanged reports whether the database file and its journal are as
+// they were when last read or written.
+func (db *DB[T]) unchanged() bool {
+	return db.statUnchanged(db.path, db.fi) && db.statUnchanged(db.journalPath(), db.j.fi)
+}
+
+func (db *DB[T]) statUnchanged(name string, old fs.FileInfo) bool {
+	fi, err := db.st.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return old == nil
+	}
+	return err == nil && old != nil && sameFile(fi, old)
+}
+
+// readMayWrite reports whether read may rewrite the database file, to
+// migrate or recover it.
+func (db *DB[T]) readMayWrite() bool {
+	return db.version > 0 || db.opts.Recover
+}
+
+// read replaces db.Data with the contents of the file and its journal,
+// or with a zero value if the file does not exist. A file stored with an
+// older schema version is migrated, backed up and rewritten, and a
+// corrupt one may be recovered, so the caller must hold the exclusive
+// file lock if readMayWrite reports true. On read-only storage the
+// migration is kept in memory only.
+func (db *DB[T]) read() error {
+	// Stat before reading: if the file is replaced in between, the
+	// next check sees a change and reads it again.
+	fi, err := db.st.Stat(db.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		db.Data = new(T)
+		db.fi = nil
+		db.j = journal{}
+		db.j.fi, _ = db.st.Stat(db.journalPath())
+		return nil
+	} else if err != nil {
+		return err
+	}
+	bs, err := db.st.ReadFile(db.path)
+	if err != nil {
+		return err
+	}
+	val, version, err := db.load(bs)
+	if errors.Is(err, ErrCorrupt) && db.opts.Recover {
+		return db.recoverFrom(err)
+	} else if err != nil {
+		return err
+	}
+	if db.opts.Journal {
+		if db.j.doc, err = toDoc(val); err != nil {
+			return err
+		}
+	}
+	db.Data = val
+	db.fi = fi
+	if version == db.version {
+		return nil
+	}
+	err = db.st.WriteFile(fmt.Sprintf("%s.v%d.bak", db.path, version), bs, 0600)
+	if errors.Is(err, ErrReadOnly) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return db.compact(val)
+}
+
+// load verifies and decodes bs, the contents of the database file, and
+// applies the journal.
+func (db *DB[T]) load(bs []byte) (*T, int, error) {
+	if err := db.verify(bs); err != nil {
+		return nil, 0, err
+	}
+	patches, err := db.readJournal(bs)
+	if err != nil {
+		return nil, 0, err
+	}
+	return db.decode(bs, patches)
+}
+
+// decode parses the contents of the database file, applies the journal
+// patches, migrates the result to the current schema version and
+// validates it. It also returns the version bs was stored with.
+func (db *DB[T]) decode(bs []byte, patches []Patch) (*T, int, error) {
+	bs, err := db.unseal(bs)
+	if errors.Is(err, ErrDecrypt) {
+		// A damaged file fails authentication like a wrong key would.
+		return nil, 0, db.corrupt(err)
+	} else if err != nil {
+		return nil, 0, err
+	}
+	val := new(T)
+	// Schemas check the JSON as stored, not as re-encoded after
+	// decoding, which would hide missing and unknown fields.
+	if db.version == 0 && len(patches) == 0 && (db.schema == nil || !isJSON(db.codec)) {
+		if err := db.codec.Unmarshal(bs, val); err != nil {
+			return nil, 0, db.corrupt(err)
+		}
+		if err := db.validate(val, nil); err != nil {
+			return nil, 0, err
+		}
+		return val, 0, nil
+	}
+
+	js, err := db.codec.(jsonFramer).toJSON(bs)
+	if err != nil {
+		return nil, 0, db.corrupt(err)
+	}
+	version, data := 0, json.RawMessage(js)
+	if db.version > 0 {
+		version, data = unwrap(js)
+	}
+	if len(patches) > 0 {
+		doc, err := parseDoc(data)
+		if err != nil {
+			return nil, 0, db.corrupt(err)
+		}
+		for _, p := range patches {
+			if doc, err = apply(doc, p); err != nil {
+				return nil, 0, db.corrupt(err)
+			}
+		}
+		data = mustRaw(doc)
+	}
+	if version > db.version {
+		return nil, 0, fmt.Errorf("jsondb: %s has schema version %d, newer than supported version %d", db.path, version, db.version)
+	}
+	if version < db.version {
+		if data, err = migrate(db.migrations, data, version, db.version); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := json.Unmarshal(data, val); err != nil {
+		return nil, 0, db.corrupt(err)
+	}
+	var doc any
+	if db.schema != nil {
+		if doc, err = parseDoc(data); err != nil {
+			return nil, 0, db.corrupt(err)
+		}
+	}
+	if err := db.validate(val, doc); err != nil {
+		return nil, 0, err
+	}
+	return val, version, nil
+}
+
+// encode returns the contents of the database file for val.
+func (db *DB[T]) encode(val *T) ([]byte, error) {
+	var bs []byte
+	if db.version == 0 {
+		var err error
+		if bs, err = db.codec.Marshal(val); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		js, err := json.Marshal(envelope{Version: db.version, Data: data})
+		if err != nil {
+			return nil, err
+		}
+		if bs, err = db.codec.(jsonFramer).fromJSON(js); err != nil {
+			return nil, err
+		}
+	}
+	return db.seal(bs)
+}
+
+// write saves val to the database, appending to the journal if enabled
+// and rewriting the whole file otherwise.
+func (db *DB[T]) write(val *T) error {
+	if err := db.validate(val, nil); err != nil {
+		return err
+	}
+	// Snapshot first: once the database file has been written, the
+	// write must not be reported as failed.
+	lastSnap := db.lastSnap
+	snap, err := db.snapshot(false)
+	if err != nil {
+		return err
+	}
+	if err := db.commit(val); err != nil {
+		if snap != "" {
+			db.st.Remove(snap)
+			db.lastSnap = lastSnap
+		}
+		return err
+	}
+	if snap != "" {
+		// Failures are left for the next snapshot to prune.
+		db.pruneSnapshots()
+	}
+	return nil
+}
+
+// commit writes val to the journal or the database file.
+func (db *DB[T]) commit(val *T) error {
+	// A missing main file or one changed behind our back cannot be
+	// journaled against, so those cases fall back to a full write.
+	if db.opts.Journal && db.fi != nil && db.unchanged() {
+		full, err := db.appendJournal(val)
+		if err != nil || !full {
+			return err
+		}
+	}
+	return db.compact(val)
+}
+
+// compact rewrites the database file with val and discards the
+// journal.
+func (db *DB[T]) compact(val *T) error {
+	bs, err := db.encode(val)
 	if err != nil {
 		return err
 	}
+	if db.opts.Checksum {
+		if err := db.writeSums(bs); err != nil {
+			return err
+		}
+	}
+	if err := db.st.WriteFile(db.path, bs, 0600); err != nil {
+		return err
+	}
+	db.fi, _ = db.st.Stat(db.path)
+	if db.j.fi != nil {
+		// The new main file no longer matches the journal header, so
+		// the journal is already void; removing it is housekeeping.
+		if err := db.st.Remove(db.journalPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	db.j = journal{base: checksum(bs)}
+	if db.opts.Journal {
+		db.j.doc, err = toDoc(val)
+	}
+	return err
+}
 
-	return writeFile(db.path, bs, 0600)
+// sameFile reports whether a and b describe the same, unmodified file.
+// Storage.WriteFile always puts a new file in place, so a replaced file
+// never satisfies os.SameFile, nor has the same Sys value in storage
+// other than OSStorage.
+func sameFile(a, b fs.FileInfo) bool {
+	same := os.SameFile(a, b) || (a.Sys() != nil && a.Sys() == b.Sys())
+	return same && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+// clone returns a deep copy of v made by round-tripping it through the
+// codec, so that only the state that would be persisted is carried over.
+func (db *DB[T]) clone(v *T) (*T, error) {
+	c := uncompressed(db.codec)
+	bs, err := c.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	val := new(T)
+	if err := c.Unmarshal(bs, val); err != nil {
+		return nil, err
+	}
+	return val, nil
 }
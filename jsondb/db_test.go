@@ -4,9 +4,11 @@ package jsondb
 // SPDX-License-Identifier: BSD-3-Clause
 
 import (
+	"errors"
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -48,6 +50,59 @@ func TestDB(t *testing.T) {
 	}
 }
 
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	db, err := Open[testDB](path)
+	if err != nil {
+		t.Fatalf("creating empty DB: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := db.Update(func(d *testDB) error {
+				d.AnInt++
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Update: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	errAbort := errors.New("abort")
+	err = db.Update(func(d *testDB) error {
+		d.AnInt = -1
+		d.MyString = "rolled back"
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Update error = %v, want %v", err, errAbort)
+	}
+
+	want := &testDB{AnInt: 10}
+	err = db.View(func(d *testDB) error {
+		if diff := cmp.Diff(d, want, cmp.AllowUnexported(testDB{})); diff != "" {
+			t.Errorf("unexpected in-memory DB content (-got+want):\n%s", diff)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+
+	db2, err := Open[testDB](path)
+	if err != nil {
+		t.Fatalf("opening DB again: %v", err)
+	}
+	if diff := cmp.Diff(db2.Data, want, cmp.AllowUnexported(testDB{})); diff != "" {
+		t.Fatalf("unexpected saved DB content (-got+want):\n%s", diff)
+	}
+}
+
 type testDB struct {
 	MyString   string
 	unexported string
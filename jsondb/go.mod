@@ -2,4 +2,7 @@ module github.com/millken/x/jsondb
 
 go 1.21.4
 
-require github.com/google/go-cmp v0.6.0
+require (
+	github.com/google/go-cmp v0.6.0
+	github.com/klauspost/compress v1.17.4
+)
@@ -0,0 +1,155 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrCorrupt matches errors reporting that the database file is
+// damaged: it fails its checksum or cannot be decoded.
+var ErrCorrupt = errors.New("jsondb: database is corrupt")
+
+// A CorruptError reports a damaged database file. It matches ErrCorrupt
+// with errors.Is.
+type CorruptError struct {
+	Path string
+	Err  error
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("jsondb: %s is corrupt: %v", e.Path, e.Err)
+}
+
+func (e *CorruptError) Unwrap() error { return e.Err }
+
+func (e *CorruptError) Is(target error) bool { return target == ErrCorrupt }
+
+func (db *DB[T]) corrupt(err error) error {
+	return &CorruptError{Path: db.path, Err: err}
+}
+
+var errChecksum = errors.New("checksum mismatch")
+
+// sums is the content of the ".sum" file kept next to the database when
+// Options.Checksum is set. It lists the checksums of the file being
+// written and of the one it replaces: the sidecar is updated before the
+// database file is renamed into place, so a crash in between leaves a
+// database that still matches.
+type sums struct {
+	SHA256 []string `json:"sha256"`
+}
+
+func (db *DB[T]) sumPath() string {
+	return db.path + ".sum"
+}
+
+// verify checks the contents bs of the database file against the
+// checksum sidecar. A missing sidecar is not an error.
+func (db *DB[T]) verify(bs []byte) error {
+	if !db.opts.Checksum {
+		return nil
+	}
+	raw, err := db.st.ReadFile(db.sumPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var s sums
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return fmt.Errorf("jsondb: reading %s: %w", db.sumPath(), err)
+	}
+	sum := checksum(bs)
+	for _, want := range s.SHA256 {
+		if sum == want {
+			return nil
+		}
+	}
+	return db.corrupt(errChecksum)
+}
+
+// writeSums records the checksum of bs, about to become the contents of
+// the database file, alongside that of the current file.
+func (db *DB[T]) writeSums(bs []byte) error {
+	s := sums{SHA256: []string{checksum(bs)}}
+	if db.fi != nil && db.j.base != "" {
+		s.SHA256 = append(s.SHA256, db.j.base)
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return db.st.WriteFile(db.sumPath(), raw, 0600)
+}
+
+// recoverFrom replaces a corrupt database with the newest readable
+// leftover temporary file or snapshot, and moves the corrupt file aside
+// with a ".corrupt" suffix.
+func (db *DB[T]) recoverFrom(cause error) error {
+	type candidate struct {
+		name    string
+		modTime time.Time
+	}
+	var cands []candidate
+	dir, base := filepath.Dir(db.path), filepath.Base(db.path)
+	des, err := db.st.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, de := range des {
+		name := de.Name()
+		if !de.Type().IsRegular() {
+			continue
+		}
+		isTemp := strings.HasPrefix(name, base+".tmp")
+		isSnap := strings.HasPrefix(name, db.snapshotPrefix()) && !strings.Contains(name, ".tmp")
+		if !isTemp && !isSnap {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			continue
+		}
+		cands = append(cands, candidate{filepath.Join(dir, name), fi.ModTime()})
+	}
+	// Modification times can tie on coarse clocks; snapshot names sort by
+	// time, and temporary files come before snapshots.
+	sort.Slice(cands, func(i, j int) bool {
+		if !cands[i].modTime.Equal(cands[j].modTime) {
+			return cands[i].modTime.After(cands[j].modTime)
+		}
+		return cands[i].name > cands[j].name
+	})
+
+	for _, c := range cands {
+		bs, err := db.st.ReadFile(c.name)
+		if err != nil {
+			continue
+		}
+		val, _, err := db.decode(bs, nil)
+		if err != nil {
+			continue
+		}
+		if err := db.st.Rename(db.path, db.path+".corrupt"); err != nil {
+			return err
+		}
+		db.fi = nil
+		db.j = journal{}
+		db.j.fi, _ = db.st.Stat(db.journalPath())
+		if err := db.compact(val); err != nil {
+			return err
+		}
+		db.Data = val
+		if strings.HasPrefix(filepath.Base(c.name), base+".tmp") {
+			db.st.Remove(c.name)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w; no valid temporary file or snapshot to recover from", cause)
+}
@@ -0,0 +1,158 @@
+package jsondb
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	opts := Options{Checksum: true}
+	db, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Data.MyString = "test"
+	if err := db.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenOptions[testDB](path, opts); err != nil {
+		t.Fatalf("opening intact DB: %v", err)
+	}
+
+	// A valid but modified file fails the checksum.
+	if err := os.WriteFile(path, []byte(`{"MyString":"edited"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenOptions[testDB](path, opts)
+	var cerr *CorruptError
+	if !errors.As(err, &cerr) || !errors.Is(err, ErrCorrupt) || cerr.Path != path {
+		t.Fatalf("opening modified DB = %v, want CorruptError", err)
+	}
+	if db, err := Open[testDB](path); err != nil || db.Data.MyString != "edited" {
+		t.Fatalf("opening modified DB without checksum = %v", err)
+	}
+
+	// A truncated file is corrupt with or without checksums.
+	if err := os.WriteFile(path, []byte(`{"MyStr`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open[testDB](path); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("opening truncated DB = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db.json")
+	opts := Options{Checksum: true, Backups: 2, Recover: true}
+	db, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := db.Update(func(d *testDB) error { d.AnInt = int64(i); return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Truncate(path, 5); err != nil {
+		t.Fatal(err)
+	}
+	db2, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatalf("recovering from snapshot: %v", err)
+	}
+	// The newest snapshot holds the contents the last update replaced.
+	if db2.Data.AnInt != 2 {
+		t.Errorf("recovered AnInt = %d, want 2", db2.Data.AnInt)
+	}
+	if _, err := os.Stat(path + ".corrupt"); err != nil {
+		t.Errorf("corrupt file not kept: %v", err)
+	}
+
+	// A complete temporary file left by a crash before the rename is
+	// newer than any snapshot.
+	if err := os.WriteFile(filepath.Join(dir, "db.json.tmp123"), []byte(`{"AnInt":4}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	db3, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatalf("recovering from temporary file: %v", err)
+	}
+	if db3.Data.AnInt != 4 {
+		t.Errorf("recovered AnInt = %d, want 4", db3.Data.AnInt)
+	}
+}
+
+func TestRecoverEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	opts := Options{Key: bytes.Repeat([]byte{1}, 32), Backups: 2, Recover: true}
+	db, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if err := db.Update(func(d *testDB) error { d.AnInt = int64(i); return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, fi.Size()-4); err != nil {
+		t.Fatal(err)
+	}
+
+	noRecover := opts
+	noRecover.Recover = false
+	if _, err := OpenOptions[testDB](path, noRecover); !errors.Is(err, ErrCorrupt) || !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("opening truncated encrypted DB = %v, want ErrCorrupt wrapping ErrDecrypt", err)
+	}
+	db2, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatalf("recovering encrypted DB: %v", err)
+	}
+	if db2.Data.AnInt != 1 {
+		t.Errorf("recovered AnInt = %d, want 1", db2.Data.AnInt)
+	}
+}
+
+func TestRecoverTakesExclusiveLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	db, err := OpenOptions[testDB](path, Options{Lock: LockShared, Recover: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold a shared lock, as another reader would.
+	reader, err := OpenOptions[testDB](path, Options{Lock: LockShared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := reader.lockFile(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	opts := Options{Lock: LockShared, LockTimeout: 50 * time.Millisecond, Recover: true}
+	if _, err := OpenOptions[testDB](path, opts); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("opening with Recover under a shared lock = %v, want ErrLockTimeout", err)
+	}
+	opts.Recover = false
+	if _, err := OpenOptions[testDB](path, opts); err != nil {
+		t.Errorf("opening without Recover under a shared lock: %v", err)
+	}
+}
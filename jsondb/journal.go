@@ -0,0 +1,234 @@
+package jsondb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// defaultJournalMaxSize is the journal size that triggers compaction
+// when Options.JournalMaxSize is zero.
+const defaultJournalMaxSize = 1 << 20
+
+// The journal is a file next to the database, named with a ".wal"
+// suffix, made of newline-terminated JSON lines. The first line is a
+// journalHeader naming the main file the journal applies to; every
+// following line is a journalRecord. When the DB is encrypted, each line
+// is sealed and base64-encoded instead. A journal whose header does not
+// match the main file is left over from an interrupted compaction and
+// is ignored, as is a torn record at the end of the file.
+
+type journalHeader struct {
+	// Base is the hex SHA-256 of the main file contents.
+	Base string `json:"base"`
+}
+
+type journalRecord struct {
+	Time  time.Time `json:"time"`
+	Patch Patch     `json:"patch"`
+}
+
+// journal is the in-memory state of a DB's journal.
+type journal struct {
+	doc   any         // generic JSON of the data as persisted
+	base  string      // checksum of the main file
+	fi    fs.FileInfo // journal file as last read or written, nil if absent
+	size  int64       // length of the valid prefix of the journal file
+	start time.Time   // time of the first record
+}
+
+func (db *DB[T]) journalPath() string {
+	return db.path + ".wal"
+}
+
+func checksum(bs []byte) string {
+	sum := sha256.Sum256(bs)
+	return hex.EncodeToString(sum[:])
+}
+
+// readJournal loads the journal that applies to the main file contents
+// main and returns its patches.
+func (db *DB[T]) readJournal(main []byte) ([]Patch, error) {
+	db.j = journal{base: checksum(main)}
+	fi, err := db.st.Stat(db.journalPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	bs, err := db.st.ReadFile(db.journalPath())
+	if err != nil {
+		return nil, err
+	}
+	db.j.fi = fi
+	// A journal of an encrypted main file must be encrypted as well, or
+	// anyone able to write it could inject changes.
+	plain := !bytes.HasPrefix(main, sealMagic)
+
+	// Lines without a terminating newline are torn writes.
+	nextLine := func() []byte {
+		i := bytes.IndexByte(bs, '\n')
+		if i < 0 {
+			return nil
+		}
+		line := bs[:i+1]
+		bs = bs[i+1:]
+		return line
+	}
+	line := nextLine()
+	if line == nil {
+		return nil, nil
+	}
+	var hdr journalHeader
+	if err := db.parseJournalLine(line, &hdr, plain); isKeyError(err) {
+		return nil, err
+	} else if err != nil || hdr.Base != db.j.base {
+		return nil, nil
+	}
+	if !isJSON(db.codec) {
+		return nil, errJournalNotJSON
+	}
+	size := int64(len(line))
+	var patches []Patch
+	for line := nextLine(); line != nil; line = nextLine() {
+		var rec journalRecord
+		if err := db.parseJournalLine(line, &rec, plain); isKeyError(err) {
+			return nil, err
+		} else if err != nil {
+			break
+		}
+		if db.j.start.IsZero() {
+			db.j.start = rec.Time
+		}
+		patches = append(patches, rec.Patch)
+		size += int64(len(line))
+	}
+	db.j.size = size
+	return patches, nil
+}
+
+// appendJournal records the change from the persisted data to val in
+// the journal. It reports whether the journal has grown enough to be
+// compacted.
+func (db *DB[T]) appendJournal(val *T) (full bool, err error) {
+	doc, err := toDoc(val)
+	if err != nil {
+		return false, err
+	}
+	p := diff(db.j.doc, doc)
+	if len(p) == 0 {
+		return db.journalFull(), nil
+	}
+	now := time.Now()
+	var buf []byte
+	if db.j.size == 0 {
+		hdr, err := db.journalLine(journalHeader{Base: db.j.base})
+		if err != nil {
+			return false, err
+		}
+		buf = hdr
+	}
+	rec, err := db.journalLine(journalRecord{Time: now, Patch: p})
+	if err != nil {
+		return false, err
+	}
+	buf = append(buf, rec...)
+
+	// Writing at the end of the valid prefix drops whatever follows: a
+	// torn record or a stale journal for another version of the main
+	// file.
+	if err := db.st.WriteAt(db.journalPath(), buf, db.j.size, 0600); err != nil {
+		return false, err
+	}
+	if db.j.fi, err = db.st.Stat(db.journalPath()); err != nil {
+		return false, err
+	}
+	db.j.size += int64(len(buf))
+	db.j.doc = doc
+	if db.j.start.IsZero() {
+		db.j.start = now
+	}
+	return db.journalFull(), nil
+}
+
+// journalLine encodes v as a newline-terminated journal line.
+func (db *DB[T]) journalLine(v any) ([]byte, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if db.keys == nil {
+		return append(js, '\n'), nil
+	}
+	sealed, err := db.seal(js)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, base64.StdEncoding.EncodedLen(len(sealed))+1)
+	base64.StdEncoding.Encode(line, sealed)
+	line[len(line)-1] = '\n'
+	return line, nil
+}
+
+// parseJournalLine decodes a line written by journalLine into v. Unless
+// plain is set, lines must be encrypted when the DB has a key.
+func (db *DB[T]) parseJournalLine(line []byte, v any, plain bool) error {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if len(line) > 0 && line[0] == '{' {
+		if db.keys != nil && !plain {
+			return errUnsealedJournal
+		}
+	} else {
+		sealed, err := base64.StdEncoding.DecodeString(string(line))
+		if err != nil {
+			return err
+		}
+		if line, err = db.unseal(sealed); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(line, v)
+}
+
+var errUnsealedJournal = fmt.Errorf("jsondb: unencrypted journal of encrypted database: %w", ErrDecrypt)
+
+func isKeyError(err error) bool {
+	return errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrDecrypt)
+}
+
+// journalFull reports whether the journal exceeds the configured size
+// or age.
+func (db *DB[T]) journalFull() bool {
+	maxSize := db.opts.JournalMaxSize
+	if maxSize == 0 {
+		maxSize = defaultJournalMaxSize
+	}
+	if db.j.size > maxSize {
+		return true
+	}
+	return db.opts.JournalMaxAge > 0 && !db.j.start.IsZero() && time.Since(db.j.start) > db.opts.JournalMaxAge
+}
+
+// Compact folds the journal into the main database file. It is only
+// needed to force compaction early; Save and Update compact
+// automatically according to Options.JournalMaxSize and
+// Options.JournalMaxAge.
+func (db *DB[T]) Compact() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	unlock, err := db.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := db.reloadLocked(); err != nil {
+		return err
+	}
+	return db.compact(db.Data)
+}
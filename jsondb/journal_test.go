@@ -0,0 +1,185 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type journalDB struct {
+	Names map[string]int
+	List  []string
+}
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	opts := Options{Journal: true, JournalMaxSize: 4096}
+	db, err := OpenOptions[journalDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first save has no main file to journal against.
+	if err := db.Save(); err != nil {
+		t.Fatal(err)
+	}
+	main, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, name := range []string{"a", "b", "c"} {
+		err := db.Update(func(d *journalDB) error {
+			if d.Names == nil {
+				d.Names = map[string]int{}
+			}
+			d.Names[name] = i
+			d.List = append(d.List, name)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.Update(func(d *journalDB) error {
+		delete(d.Names, "a")
+		d.List = d.List[1:]
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bs, _ := os.ReadFile(path); string(bs) != string(main) {
+		t.Errorf("main file rewritten before compaction: %s", bs)
+	}
+	if _, err := os.Stat(path + ".wal"); err != nil {
+		t.Fatalf("journal missing: %v", err)
+	}
+
+	want := &journalDB{Names: map[string]int{"b": 1, "c": 2}, List: []string{"b", "c"}}
+	db2, err := OpenOptions[journalDB](path, opts)
+	if err != nil {
+		t.Fatalf("replaying journal: %v", err)
+	}
+	if diff := cmp.Diff(db2.Data, want); diff != "" {
+		t.Fatalf("unexpected replayed DB content (-got+want):\n%s", diff)
+	}
+
+	if err := db2.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Errorf("journal not removed by compaction: %v", err)
+	}
+	db3, err := Open[journalDB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(db3.Data, want); diff != "" {
+		t.Fatalf("unexpected compacted DB content (-got+want):\n%s", diff)
+	}
+}
+
+func TestJournalTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	opts := Options{Journal: true}
+	db, err := OpenOptions[journalDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(d *journalDB) error { d.List = []string{"x"}; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2006-01-02T15:04:05Z","patch":[{"op":"add","pa`)
+	f.Close()
+
+	db2, err := OpenOptions[journalDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(db2.Data, &journalDB{List: []string{"x"}}); diff != "" {
+		t.Fatalf("unexpected DB content (-got+want):\n%s", diff)
+	}
+	if err := db2.Update(func(d *journalDB) error { d.List = append(d.List, "y"); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	db3, err := OpenOptions[journalDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(db3.Data, &journalDB{List: []string{"x", "y"}}); diff != "" {
+		t.Fatalf("unexpected DB content after torn record (-got+want):\n%s", diff)
+	}
+}
+
+type largeNumbers struct {
+	I int64
+	U uint64
+}
+
+func TestJournalLargeNumbers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	opts := Options{Journal: true}
+	db, err := OpenOptions[largeNumbers](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(d *largeNumbers) error {
+		d.I, d.U = 1<<53, 1<<62
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both values round to the previous ones as float64.
+	want := &largeNumbers{I: 1<<53 + 1, U: 1<<62 + 1}
+	err = db.Update(func(d *largeNumbers) error {
+		*d = *want
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".wal"); err != nil {
+		t.Fatalf("journal missing: %v", err)
+	}
+
+	db2, err := OpenOptions[largeNumbers](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(db2.Data, want); diff != "" {
+		t.Errorf("unexpected replayed DB content (-got+want):\n%s", diff)
+	}
+}
+
+func TestEqualNumber(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1", "1", true},
+		{"1", "1.0", true},
+		{"1e2", "100", true},
+		{"0.1", "1e-1", true},
+		{"9007199254740992", "9007199254740993", false},
+		{"4611686018427387904", "4611686018427387905", false},
+		{"0.30000000000000001", "0.3", false},
+	}
+	for _, tt := range tests {
+		if got := equalNumber(json.Number(tt.a), json.Number(tt.b)); got != tt.want {
+			t.Errorf("equalNumber(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package jsondb
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// LockMode selects the advisory file locking used by a DB.
+type LockMode int
+
+const (
+	// LockNone takes no file lock. Only one process may use the
+	// database file at a time.
+	LockNone LockMode = iota
+
+	// LockShared takes a shared lock while reading the file and an
+	// exclusive lock while writing it, so several processes may read
+	// concurrently but writes are serialized.
+	LockShared
+
+	// LockExclusive takes an exclusive lock for reads and writes alike.
+	LockExclusive
+)
+
+// ErrLockTimeout is returned when the advisory file lock could not be
+// acquired within Options.LockTimeout.
+var ErrLockTimeout = errors.New("jsondb: timed out waiting for file lock")
+
+// lockPollInterval is how often a lock with a timeout is retried.
+const lockPollInterval = 10 * time.Millisecond
+
+// lockFile acquires the advisory lock on the sidecar lock file
+// according to db.opts and returns a function that releases it. write
+// reports whether the caller is about to modify the database file.
+func (db *DB[T]) lockFile(write bool) (unlock func(), err error) {
+	if db.opts.Lock == LockNone {
+		return func() {}, nil
+	}
+	exclusive := write || db.opts.Lock == LockExclusive
+	f, err := os.OpenFile(db.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := acquire(f, exclusive, db.opts.LockTimeout); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		release(f)
+		f.Close()
+	}, nil
+}
+
+// acquire locks f, polling until timeout if it is positive.
+func acquire(f *os.File, exclusive bool, timeout time.Duration) error {
+	if timeout <= 0 {
+		return flock(f, exclusive, true)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		err := flock(f, exclusive, false)
+		if !errors.Is(err, errWouldBlock) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return ErrLockTimeout
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package jsondb
+
+import (
+	"errors"
+	"os"
+)
+
+var errWouldBlock = errors.New("jsondb: lock would block")
+
+// errLockUnsupported is returned when file locking is requested on a
+// platform without flock(2).
+var errLockUnsupported = errors.New("jsondb: file locking is not supported on this platform")
+
+func flock(f *os.File, exclusive, block bool) error {
+	return errLockUnsupported
+}
+
+func release(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,72 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package jsondb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockedUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	opts := Options{Lock: LockShared}
+	// Two handles on the same file stand in for two processes; flock
+	// locks belong to the open file, so they exclude each other.
+	a, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := OpenOptions[testDB](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for _, db := range []*DB[testDB]{a, b} {
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(db *DB[testDB]) {
+				defer wg.Done()
+				if err := db.Update(func(d *testDB) error { d.AnInt++; return nil }); err != nil {
+					t.Errorf("Update: %v", err)
+				}
+			}(db)
+		}
+	}
+	wg.Wait()
+
+	if err := a.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if a.Data.AnInt != 40 {
+		t.Fatalf("AnInt = %d, want 40", a.Data.AnInt)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	db, err := OpenOptions[testDB](path, Options{Lock: LockShared, LockTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := flock(f, true, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("Save with lock held = %v, want ErrLockTimeout", err)
+	}
+	release(f)
+	if err := db.Save(); err != nil {
+		t.Fatalf("Save after release: %v", err)
+	}
+}
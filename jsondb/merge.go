@@ -0,0 +1,140 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Diff returns a JSON Patch (RFC 6902) that transforms the JSON encoding
+// of a into that of b. Arrays that differ in length are patched at
+// their end, so the patch is correct but not necessarily minimal.
+func Diff[T any](a, b *T) (Patch, error) {
+	da, err := toDoc(a)
+	if err != nil {
+		return nil, err
+	}
+	db, err := toDoc(b)
+	if err != nil {
+		return nil, err
+	}
+	return diff(da, db), nil
+}
+
+// A Conflict is a value changed differently by both sides of a Merge.
+type Conflict struct {
+	// Path is the JSON Pointer (RFC 6901) of the value.
+	Path string
+
+	// Base, Ours and Theirs are the JSON encodings of the value in each
+	// input, nil where it is absent.
+	Base, Ours, Theirs json.RawMessage
+}
+
+// absent stands for a missing object member during a merge.
+type absent struct{}
+
+// Merge performs a three-way merge of the JSON encodings of ours and
+// theirs, two modifications of base. Changes made on only one side are
+// taken from that side. Where both sides changed the same value
+// differently, the result keeps ours and the conflict is reported.
+// Objects are merged member by member; arrays and scalars are merged as
+// a whole.
+func Merge[T any](base, ours, theirs *T) (*T, []Conflict, error) {
+	var docs [3]any
+	for i, v := range []*T{base, ours, theirs} {
+		d, err := toDoc(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		docs[i] = d
+	}
+	var conflicts []Conflict
+	merged := merge(&conflicts, "", docs[0], docs[1], docs[2])
+	val := new(T)
+	if err := json.Unmarshal(mustRaw(merged), val); err != nil {
+		return nil, nil, err
+	}
+	return val, conflicts, nil
+}
+
+func merge(conflicts *[]Conflict, path string, base, ours, theirs any) any {
+	switch {
+	case equalMerge(ours, theirs), equalMerge(base, theirs):
+		return ours
+	case equalMerge(base, ours):
+		return theirs
+	}
+	om, ok1 := ours.(map[string]any)
+	tm, ok2 := theirs.(map[string]any)
+	if ok1 && ok2 {
+		bm, _ := base.(map[string]any)
+		keys := make([]string, 0, len(om)+len(tm))
+		for k := range om {
+			keys = append(keys, k)
+		}
+		for k := range tm {
+			if _, ok := om[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		out := make(map[string]any, len(keys))
+		for _, k := range keys {
+			v := merge(conflicts, path+"/"+escapePointer(k), member(bm, k), member(om, k), member(tm, k))
+			if _, ok := v.(absent); !ok {
+				out[k] = v
+			}
+		}
+		return out
+	}
+	*conflicts = append(*conflicts, Conflict{
+		Path:   path,
+		Base:   rawMember(base),
+		Ours:   rawMember(ours),
+		Theirs: rawMember(theirs),
+	})
+	return ours
+}
+
+func member(m map[string]any, k string) any {
+	if v, ok := m[k]; ok {
+		return v
+	}
+	return absent{}
+}
+
+func rawMember(v any) json.RawMessage {
+	if _, ok := v.(absent); ok {
+		return nil
+	}
+	return mustRaw(v)
+}
+
+func equalMerge(a, b any) bool {
+	_, aa := a.(absent)
+	_, ba := b.(absent)
+	if aa || ba {
+		return aa == ba
+	}
+	return equalDoc(a, b)
+}
+
+// Apply applies patch to the contents of the database and saves the
+// result, like Update. If any operation fails, nothing is changed.
+func (db *DB[T]) Apply(patch Patch) error {
+	return db.Update(func(v *T) error {
+		doc, err := toDoc(v)
+		if err != nil {
+			return err
+		}
+		if doc, err = apply(doc, patch); err != nil {
+			return err
+		}
+		var next T
+		if err := json.Unmarshal(mustRaw(doc), &next); err != nil {
+			return err
+		}
+		*v = next
+		return nil
+	})
+}
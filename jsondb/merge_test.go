@@ -0,0 +1,74 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type mergeConfig struct {
+	Name    string            `json:"name"`
+	Port    int               `json:"port"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Servers []string          `json:"servers,omitempty"`
+}
+
+func TestDiffAndApply(t *testing.T) {
+	a := &mergeConfig{Name: "web", Port: 80, Labels: map[string]string{"env": "prod"}}
+	b := &mergeConfig{Name: "web", Port: 8080, Servers: []string{"a", "b"}}
+	p, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenOptions[mergeConfig](filepath.Join(t.TempDir(), "db.json"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(c *mergeConfig) error { *c = *a; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Apply(p); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(db.Data, b); diff != "" {
+		t.Errorf("unexpected content after Apply (-got+want):\n%s", diff)
+	}
+
+	bad := Patch{
+		{Op: "replace", Path: "/port", Value: json.RawMessage(`1`)},
+		{Op: "test", Path: "/name", Value: json.RawMessage(`"api"`)},
+	}
+	if err := db.Apply(bad); err == nil {
+		t.Fatal("Apply with failing test succeeded")
+	}
+	if db.Data.Port != 8080 {
+		t.Errorf("failed Apply changed Port to %d", db.Data.Port)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := &mergeConfig{Name: "web", Port: 80, Labels: map[string]string{"env": "prod", "team": "a"}}
+	ours := &mergeConfig{Name: "web", Port: 8080, Labels: map[string]string{"env": "prod", "team": "b"}}
+	theirs := &mergeConfig{Name: "www", Port: 80, Labels: map[string]string{"team": "c"}, Servers: []string{"x"}}
+
+	got, conflicts, err := Merge(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &mergeConfig{Name: "www", Port: 8080, Labels: map[string]string{"team": "b"}, Servers: []string{"x"}}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("unexpected merge (-got+want):\n%s", diff)
+	}
+	wantConflicts := []Conflict{{
+		Path:   "/labels/team",
+		Base:   json.RawMessage(`"a"`),
+		Ours:   json.RawMessage(`"b"`),
+		Theirs: json.RawMessage(`"c"`),
+	}}
+	if diff := cmp.Diff(conflicts, wantConflicts); diff != "" {
+		t.Errorf("unexpected conflicts (-got+want):\n%s", diff)
+	}
+}
@@ -0,0 +1,109 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// A MigrateFunc converts the JSON encoding of a database from one schema
+// version to the next.
+type MigrateFunc func(json.RawMessage) (json.RawMessage, error)
+
+type migration struct {
+	from, to int
+	fn       MigrateFunc
+}
+
+var (
+	migrationsMu sync.Mutex
+	migrations   = map[reflect.Type][]migration{}
+)
+
+// RegisterMigration registers fn to convert databases of type T stored
+// with schema version from to schema version to. The highest to
+// registered for T becomes the current schema version, which Save
+// records in the file.
+//
+// Open runs registered migrations in sequence until the file reaches the
+// current version, saves the result and keeps a copy of the original
+// file next to it, named after the version it was stored with (for
+// example "db.json.v1.bak"). Files written before any migration was
+// registered are treated as version 0.
+//
+// RegisterMigration is meant to be called from init functions. It
+// panics if to is not greater than from or if a migration from the same
+// version is already registered for T.
+func RegisterMigration[T any](from, to int, fn MigrateFunc) {
+	if from < 0 || to <= from {
+		panic(fmt.Sprintf("jsondb: invalid migration from version %d to %d", from, to))
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	for _, m := range migrations[typ] {
+		if m.from == from {
+			panic(fmt.Sprintf("jsondb: duplicate migration from version %d for %v", from, typ))
+		}
+	}
+	migrations[typ] = append(migrations[typ], migration{from, to, fn})
+}
+
+// migrationsFor returns the migrations registered for T and the current
+// schema version they define.
+func migrationsFor[T any]() (ms []migration, version int) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	ms = append(ms, migrations[typ]...)
+	for _, m := range ms {
+		version = max(version, m.to)
+	}
+	return ms, version
+}
+
+// migrate runs ms on data, stored with schema version from, until it
+// reaches version to.
+func migrate(ms []migration, data json.RawMessage, from, to int) (json.RawMessage, error) {
+	for v := from; v != to; {
+		var next *migration
+		for i := range ms {
+			if ms[i].from == v {
+				next = &ms[i]
+				break
+			}
+		}
+		if next == nil {
+			return nil, fmt.Errorf("jsondb: no migration registered from version %d", v)
+		}
+		var err error
+		data, err = next.fn(data)
+		if err != nil {
+			return nil, fmt.Errorf("jsondb: migrating from version %d to %d: %w", next.from, next.to, err)
+		}
+		v = next.to
+	}
+	return data, nil
+}
+
+// envelope is the on-disk form of a database with a schema version.
+type envelope struct {
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// unwrap splits bs into the schema version and the encoded data. A
+// document whose only fields are "version" and "data" is an envelope;
+// anything else is version 0 data.
+func unwrap(bs []byte) (version int, data json.RawMessage) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(bs, &fields) != nil || len(fields) != 2 {
+		return 0, bs
+	}
+	var env envelope
+	if _, ok := fields["data"]; !ok || json.Unmarshal(fields["version"], &env.Version) != nil {
+		return 0, bs
+	}
+	return env.Version, fields["data"]
+}
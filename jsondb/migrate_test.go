@@ -0,0 +1,79 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type migratedDB struct {
+	Name  string
+	Ports []int
+}
+
+func init() {
+	// Version 0 stored a single "port"; version 1 renamed "name" to
+	// "Name"; version 2 turned "port" into a list.
+	RegisterMigration[migratedDB](0, 1, func(data json.RawMessage) (json.RawMessage, error) {
+		var m map[string]any
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		m["Name"] = m["name"]
+		delete(m, "name")
+		return json.Marshal(m)
+	})
+	RegisterMigration[migratedDB](1, 2, func(data json.RawMessage) (json.RawMessage, error) {
+		var m map[string]any
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		m["Ports"] = []any{m["port"]}
+		delete(m, "port")
+		return json.Marshal(m)
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	const v0 = `{"name":"web","port":80}`
+	if err := os.WriteFile(path, []byte(v0), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open[migratedDB](path)
+	if err != nil {
+		t.Fatalf("opening version 0 DB: %v", err)
+	}
+	want := &migratedDB{Name: "web", Ports: []int{80}}
+	if diff := cmp.Diff(db.Data, want); diff != "" {
+		t.Fatalf("unexpected migrated DB content (-got+want):\n%s", diff)
+	}
+
+	backup, err := os.ReadFile(path + ".v0.bak")
+	if err != nil {
+		t.Fatalf("reading backup: %v", err)
+	}
+	if string(backup) != v0 {
+		t.Errorf("backup = %q, want %q", backup, v0)
+	}
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := unwrap(bs); got != 2 {
+		t.Errorf("saved schema version = %d, want 2", got)
+	}
+
+	db2, err := Open[migratedDB](path)
+	if err != nil {
+		t.Fatalf("opening migrated DB: %v", err)
+	}
+	if diff := cmp.Diff(db2.Data, want); diff != "" {
+		t.Fatalf("unexpected reopened DB content (-got+want):\n%s", diff)
+	}
+}
@@ -0,0 +1,401 @@
+package jsondb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An Operation is a single JSON Patch (RFC 6902) operation.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// A Patch is a JSON Patch (RFC 6902) document.
+type Patch []Operation
+
+// toDoc returns the generic JSON representation of v: nil, bool,
+// json.Number, string, []any or map[string]any.
+func toDoc(v any) (any, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return parseDoc(bs)
+}
+
+// parseDoc parses a JSON document into its generic representation.
+func parseDoc(bs []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// equalNumber compares two JSON numbers exactly, so that integers beyond
+// the precision of float64 still differ.
+func equalNumber(a, b json.Number) bool {
+	if a == b {
+		return true
+	}
+	ar, aok := new(big.Rat).SetString(string(a))
+	br, bok := new(big.Rat).SetString(string(b))
+	return aok && bok && ar.Cmp(br) == 0
+}
+
+// diff returns a patch that transforms the document a into b.
+func diff(a, b any) Patch {
+	var p Patch
+	diffAt(&p, "", a, b)
+	return p
+}
+
+func diffAt(p *Patch, path string, a, b any) {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			av, inA := a[k]
+			bv, inB := b[k]
+			kp := path + "/" + escapePointer(k)
+			switch {
+			case !inB:
+				*p = append(*p, Operation{Op: "remove", Path: kp})
+			case !inA:
+				*p = append(*p, Operation{Op: "add", Path: kp, Value: mustRaw(bv)})
+			default:
+				diffAt(p, kp, av, bv)
+			}
+		}
+		return
+	case []any:
+		b, ok := b.([]any)
+		if !ok {
+			break
+		}
+		n := min(len(a), len(b))
+		for i := 0; i < n; i++ {
+			diffAt(p, path+"/"+strconv.Itoa(i), a[i], b[i])
+		}
+		for i := len(a) - 1; i >= n; i-- {
+			*p = append(*p, Operation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		for i := n; i < len(b); i++ {
+			*p = append(*p, Operation{Op: "add", Path: path + "/-", Value: mustRaw(b[i])})
+		}
+		return
+	}
+	if !equalDoc(a, b) {
+		*p = append(*p, Operation{Op: "replace", Path: path, Value: mustRaw(b)})
+	}
+}
+
+// mustRaw encodes a generic JSON value, which cannot fail.
+func mustRaw(v any) json.RawMessage {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return bs
+}
+
+// equalDoc reports whether two generic JSON values are equal.
+func equalDoc(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !equalDoc(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalDoc(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		return equalNumber(a, b)
+	default:
+		return a == b
+	}
+}
+
+// copyDoc returns a deep copy of a generic JSON value.
+func copyDoc(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = copyDoc(e)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, e := range v {
+			s[i] = copyDoc(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// apply applies p to doc and returns the resulting document. doc may be
+// modified in place, so callers that need to keep it on failure must
+// pass a copy.
+func apply(doc any, p Patch) (any, error) {
+	for _, op := range p {
+		var err error
+		if doc, err = applyOp(doc, op); err != nil {
+			return nil, fmt.Errorf("jsondb: patch %s %q: %w", op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func applyOp(doc any, op Operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+		v, err := parseDoc(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return addAt(doc, path, v)
+		case "replace":
+			if doc, _, err = removeAt(doc, path); err != nil {
+				return nil, err
+			}
+			return addAt(doc, path, v)
+		default:
+			cur, err := getAt(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !equalDoc(cur, v) {
+				return nil, fmt.Errorf("test failed")
+			}
+			return doc, nil
+		}
+	case "remove":
+		doc, _, err = removeAt(doc, path)
+		return doc, err
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		var v any
+		if op.Op == "move" {
+			if op.Path == op.From {
+				return doc, nil
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, fmt.Errorf("cannot move %q into itself", op.From)
+			}
+			if doc, v, err = removeAt(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			if v, err = getAt(doc, from); err != nil {
+				return nil, err
+			}
+			v = copyDoc(v)
+		}
+		return addAt(doc, path, v)
+	default:
+		return nil, fmt.Errorf("unknown operation")
+	}
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into reference tokens.
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", s)
+	}
+	toks := strings.Split(s[1:], "/")
+	for i, t := range toks {
+		toks[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return toks, nil
+}
+
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// arrayIndex parses an array index token for an array of length n. If
+// end is true, the token may also address the position after the last
+// element.
+func arrayIndex(tok string, n int, end bool) (int, error) {
+	if end && tok == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || (tok != "0" && tok[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	if i > n || (i == n && !end) {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func getAt(doc any, path []string) (any, error) {
+	for _, tok := range path {
+		switch n := doc.(type) {
+		case map[string]any:
+			v, ok := n[tok]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", tok)
+			}
+			doc = v
+		case []any:
+			i, err := arrayIndex(tok, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = n[i]
+		default:
+			return nil, fmt.Errorf("cannot index %T with %q", doc, tok)
+		}
+	}
+	return doc, nil
+}
+
+// updateParent walks doc to the container holding the last token of
+// path, replaces that container with the result of fn and returns the
+// updated document.
+func updateParent(doc any, path []string, fn func(parent any, tok string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	switch n := doc.(type) {
+	case map[string]any:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", path[0])
+		}
+		child, err := updateParent(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = child
+		return n, nil
+	case []any:
+		i, err := arrayIndex(path[0], len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := updateParent(n[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = child
+		return n, nil
+	default:
+		return nil, fmt.Errorf("cannot index %T with %q", doc, path[0])
+	}
+}
+
+func addAt(doc any, path []string, v any) (any, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	return updateParent(doc, path, func(parent any, tok string) (any, error) {
+		switch n := parent.(type) {
+		case map[string]any:
+			n[tok] = v
+			return n, nil
+		case []any:
+			i, err := arrayIndex(tok, len(n), true)
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = v
+			return n, nil
+		default:
+			return nil, fmt.Errorf("cannot add to %T", parent)
+		}
+	})
+}
+
+func removeAt(doc any, path []string) (newDoc, removed any, err error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	newDoc, err = updateParent(doc, path, func(parent any, tok string) (any, error) {
+		switch n := parent.(type) {
+		case map[string]any:
+			v, ok := n[tok]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", tok)
+			}
+			removed = v
+			delete(n, tok)
+			return n, nil
+		case []any:
+			i, err := arrayIndex(tok, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = n[i]
+			return append(n[:i], n[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot remove from %T", parent)
+		}
+	})
+	return newDoc, removed, err
+}
@@ -0,0 +1,89 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiffApply(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{`{}`, `{"a":1}`},
+		{`{"a":1,"b":[1,2,3]}`, `{"a":2,"b":[1]}`},
+		{`{"a/b":{"c~d":true}}`, `{"a/b":{"c~d":false,"e":null}}`},
+		{`[1,{"x":[]}]`, `[1,{"x":["y"]},3]`},
+		{`{"a":[1]}`, `{"a":{"0":1}}`},
+		{`"x"`, `{"x":1}`},
+	}
+	for _, tt := range tests {
+		a, err := parseDoc([]byte(tt.a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := parseDoc([]byte(tt.b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := diff(a, b)
+		got, err := apply(copyDoc(a), p)
+		if err != nil {
+			t.Errorf("apply(%s, diff) failed: %v", tt.a, err)
+			continue
+		}
+		if !equalDoc(got, b) {
+			t.Errorf("apply(%s, diff(%s, %s)) = %s", tt.a, tt.a, tt.b, mustRaw(got))
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	// Examples from RFC 6902, appendix A.
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"}]`, `{"foo":{"bar":1},"baz":{"bar":1}}`},
+	}
+	for _, tt := range tests {
+		doc, _ := parseDoc([]byte(tt.doc))
+		want, _ := parseDoc([]byte(tt.want))
+		var p Patch
+		if err := json.Unmarshal([]byte(tt.patch), &p); err != nil {
+			t.Fatal(err)
+		}
+		got, err := apply(doc, p)
+		if err != nil {
+			t.Errorf("apply(%s, %s): %v", tt.doc, tt.patch, err)
+			continue
+		}
+		if !equalDoc(got, want) {
+			t.Errorf("apply(%s, %s) = %s, want %s", tt.doc, tt.patch, mustRaw(got), tt.want)
+		}
+	}
+
+	failures := []struct {
+		doc, patch string
+	}{
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`},
+		{`{"foo":[]}`, `[{"op":"remove","path":"/foo/0"}]`},
+		{`{"foo":[1]}`, `[{"op":"add","path":"/foo/01","value":2}]`},
+	}
+	for _, tt := range failures {
+		doc, _ := parseDoc([]byte(tt.doc))
+		var p Patch
+		if err := json.Unmarshal([]byte(tt.patch), &p); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := apply(doc, p); err == nil {
+			t.Errorf("apply(%s, %s) succeeded, want error", tt.doc, tt.patch)
+		}
+	}
+}
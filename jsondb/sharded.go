@@ -0,0 +1,329 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// defaultShards is the number of shards of a new store when
+// ShardOptions.Shards is zero.
+const defaultShards = 16
+
+const manifestName = "MANIFEST.json"
+
+// ShardOptions configures a Sharded store.
+type ShardOptions struct {
+	// Shards is the number of shard files of a new store. It defaults
+	// to 16. Opening an existing store with a different non-zero value
+	// fails; use Reshard to change it.
+	Shards int
+
+	// Codec encodes the shard files. The default is JSON.
+	Codec Codec
+}
+
+// manifest is the content of MANIFEST.json. Replacing it is the commit
+// point of every write: shard files are never modified in place, but
+// written under a new name and then referenced by a new manifest.
+type manifest struct {
+	Generation int64    `json:"generation"`
+	Hash       string   `json:"hash"`
+	Files      []string `json:"files"` // per shard; "" for an empty shard
+}
+
+// Sharded is a key-value store whose records are spread over several
+// files in a directory by a hash of their key, so that a write only
+// rewrites the shards it touches. A Sharded store must only be used by
+// one process at a time.
+type Sharded[V any] struct {
+	dir   string
+	codec Codec
+
+	mu     sync.RWMutex
+	m      manifest
+	shards []map[string]V
+}
+
+// OpenSharded opens the sharded store in dir, creating the directory if
+// necessary.
+func OpenSharded[V any](dir string, opts ShardOptions) (*Sharded[V], error) {
+	s := &Sharded[V]{dir: dir, codec: opts.Codec}
+	if s.codec == nil {
+		s.codec = JSON
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	bs, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		n := opts.Shards
+		if n <= 0 {
+			n = defaultShards
+		}
+		s.m = manifest{Hash: "fnv1a64", Files: make([]string, n)}
+	} else if err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(bs, &s.m); err != nil {
+		return nil, &CorruptError{Path: filepath.Join(dir, manifestName), Err: err}
+	}
+	if s.m.Hash != "fnv1a64" || len(s.m.Files) == 0 {
+		return nil, fmt.Errorf("jsondb: unsupported manifest in %s", dir)
+	}
+	if opts.Shards > 0 && opts.Shards != len(s.m.Files) {
+		return nil, fmt.Errorf("jsondb: %s has %d shards, not %d; use Reshard", dir, len(s.m.Files), opts.Shards)
+	}
+
+	s.shards = make([]map[string]V, len(s.m.Files))
+	for i, name := range s.m.Files {
+		s.shards[i] = make(map[string]V)
+		if name == "" {
+			continue
+		}
+		bs, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if err := s.codec.Unmarshal(bs, &s.shards[i]); err != nil {
+			return nil, &CorruptError{Path: filepath.Join(dir, name), Err: err}
+		}
+	}
+	if err := s.removeUnreferenced(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// removeUnreferenced deletes shard files and temporary files left behind
+// by commits that were interrupted or superseded.
+func (s *Sharded[V]) removeUnreferenced() error {
+	des, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool)
+	for _, name := range s.m.Files {
+		keep[name] = true
+	}
+	for _, de := range des {
+		name := de.Name()
+		if keep[name] || !(strings.HasPrefix(name, "shard-") || strings.HasPrefix(name, manifestName+".tmp")) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func shardOf(key string, n int) int {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return int(h.Sum64() % uint64(n))
+}
+
+// Shards returns the number of shards.
+func (s *Sharded[V]) Shards() int {
+	return len(s.shards)
+}
+
+// Get returns the value stored under key.
+func (s *Sharded[V]) Get(key string) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.shards[shardOf(key, len(s.shards))][key]
+	return v, ok
+}
+
+// Len returns the number of records in the store.
+func (s *Sharded[V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, m := range s.shards {
+		n += len(m)
+	}
+	return n
+}
+
+// Range calls fn for each record, in no particular order, until fn
+// returns false. fn must not modify the store.
+func (s *Sharded[V]) Range(fn func(key string, v V) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, m := range s.shards {
+		for k, v := range m {
+			if !fn(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Put stores v under key.
+func (s *Sharded[V]) Put(key string, v V) error {
+	return s.Update(func(tx *ShardTx[V]) error {
+		tx.Put(key, v)
+		return nil
+	})
+}
+
+// Delete removes the record stored under key, if any.
+func (s *Sharded[V]) Delete(key string) error {
+	return s.Update(func(tx *ShardTx[V]) error {
+		tx.Delete(key)
+		return nil
+	})
+}
+
+// A ShardTx collects the changes of an Update.
+type ShardTx[V any] struct {
+	s       *Sharded[V]
+	changes map[string]*V // nil deletes the key
+}
+
+// Get returns the value stored under key, including changes made in tx.
+func (tx *ShardTx[V]) Get(key string) (V, bool) {
+	if v, ok := tx.changes[key]; ok {
+		if v == nil {
+			var zero V
+			return zero, false
+		}
+		return *v, true
+	}
+	v, ok := tx.s.shards[shardOf(key, len(tx.s.shards))][key]
+	return v, ok
+}
+
+// Put stores v under key.
+func (tx *ShardTx[V]) Put(key string, v V) {
+	tx.changes[key] = &v
+}
+
+// Delete removes the record stored under key.
+func (tx *ShardTx[V]) Delete(key string) {
+	tx.changes[key] = nil
+}
+
+// Update calls fn to collect changes and commits them atomically: after
+// a crash, either all or none of them are visible, however many shards
+// they touch. If fn returns an error, nothing is written.
+func (s *Sharded[V]) Update(fn func(tx *ShardTx[V]) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx := &ShardTx[V]{s: s, changes: make(map[string]*V)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.changes) == 0 {
+		return nil
+	}
+
+	next := make(map[int]map[string]V)
+	for k, v := range tx.changes {
+		i := shardOf(k, len(s.shards))
+		m, ok := next[i]
+		if !ok {
+			m = make(map[string]V, len(s.shards[i])+1)
+			for k, v := range s.shards[i] {
+				m[k] = v
+			}
+			next[i] = m
+		}
+		if v == nil {
+			delete(m, k)
+		} else {
+			m[k] = *v
+		}
+	}
+	if err := s.commit(next); err != nil {
+		return err
+	}
+	for i, m := range next {
+		s.shards[i] = m
+	}
+	return nil
+}
+
+// commit writes the shards in next under new names and then a manifest
+// referencing them, and removes the files they replace.
+func (s *Sharded[V]) commit(next map[int]map[string]V) error {
+	m := manifest{
+		Generation: s.m.Generation + 1,
+		Hash:       s.m.Hash,
+		Files:      append([]string(nil), s.m.Files...),
+	}
+	for i, shard := range next {
+		if len(shard) == 0 {
+			m.Files[i] = ""
+			continue
+		}
+		bs, err := s.codec.Marshal(shard)
+		if err != nil {
+			return err
+		}
+		m.Files[i] = fmt.Sprintf("shard-%04d-%d%s", i, m.Generation, s.codec.Ext())
+		if err := writeFile(filepath.Join(s.dir, m.Files[i]), bs, 0600); err != nil {
+			return err
+		}
+	}
+	bs, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(s.dir, manifestName), bs, 0600); err != nil {
+		return err
+	}
+	old := s.m
+	s.m = m
+	for i := range next {
+		if old.Files[i] != "" {
+			os.Remove(filepath.Join(s.dir, old.Files[i]))
+		}
+	}
+	return nil
+}
+
+// Reshard changes the number of shards of the store in dir. The store
+// must not be open while it runs. Like any other commit, resharding is
+// atomic.
+func Reshard[V any](dir string, shards int, opts ShardOptions) error {
+	if shards <= 0 {
+		return fmt.Errorf("jsondb: invalid shard count %d", shards)
+	}
+	opts.Shards = 0
+	s, err := OpenSharded[V](dir, opts)
+	if err != nil {
+		return err
+	}
+	if shards == len(s.shards) {
+		return nil
+	}
+	next := make(map[int]map[string]V, shards)
+	for i := 0; i < shards; i++ {
+		next[i] = make(map[string]V)
+	}
+	for _, m := range s.shards {
+		for k, v := range m {
+			next[shardOf(k, shards)][k] = v
+		}
+	}
+	old := s.m.Files
+	s.m.Files = make([]string, shards)
+	if err := s.commit(next); err != nil {
+		return err
+	}
+	for _, name := range old {
+		if name != "" {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+	return nil
+}
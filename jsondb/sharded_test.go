@@ -0,0 +1,109 @@
+package jsondb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func shardFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, de := range des {
+		if strings.HasPrefix(de.Name(), "shard-") {
+			names = append(names, de.Name())
+		}
+	}
+	return names
+}
+
+func TestSharded(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	s, err := OpenSharded[int](dir, ShardOptions{Shards: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Update(func(tx *ShardTx[int]) error {
+		for i := 0; i < 100; i++ {
+			tx.Put(fmt.Sprint("key", i), i)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("key7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("key8", 800); err != nil {
+		t.Fatal(err)
+	}
+	errAbort := errors.New("abort")
+	err = s.Update(func(tx *ShardTx[int]) error {
+		tx.Put("key9", -1)
+		if v, _ := tx.Get("key9"); v != -1 {
+			t.Errorf("tx.Get(key9) = %d, want -1", v)
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("aborted Update = %v", err)
+	}
+	if got := len(shardFiles(t, dir)); got != 4 {
+		t.Errorf("%d shard files, want 4", got)
+	}
+
+	// A shard file from an interrupted commit is cleaned up.
+	stray := filepath.Join(dir, "shard-0000-99.json")
+	if err := os.WriteFile(stray, []byte(`{"stray":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	check := func(s *Sharded[int]) {
+		t.Helper()
+		if s.Len() != 99 {
+			t.Errorf("Len = %d, want 99", s.Len())
+		}
+		if _, ok := s.Get("key7"); ok {
+			t.Error("deleted key7 present")
+		}
+		if v, _ := s.Get("key8"); v != 800 {
+			t.Errorf("key8 = %d, want 800", v)
+		}
+		if v, _ := s.Get("key9"); v != 9 {
+			t.Errorf("key9 = %d, want 9", v)
+		}
+		if _, ok := s.Get("stray"); ok {
+			t.Error("stray record present")
+		}
+	}
+	s2, err := OpenSharded[int](dir, ShardOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(s2)
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Errorf("stray shard file not removed: %v", err)
+	}
+
+	if _, err := OpenSharded[int](dir, ShardOptions{Shards: 7}); err == nil {
+		t.Error("opening with a different shard count succeeded")
+	}
+	if err := Reshard[int](dir, 7, ShardOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	s3, err := OpenSharded[int](dir, ShardOptions{Shards: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(s3)
+	if got := len(shardFiles(t, dir)); got != 7 {
+		t.Errorf("%d shard files after Reshard, want 7", got)
+	}
+}
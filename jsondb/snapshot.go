@@ -0,0 +1,184 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotLayout formats snapshot times, which double as snapshot IDs.
+// It sorts lexically in time order.
+const snapshotLayout = "20060102T150405.000000000Z"
+
+// A Snapshot is a copy of the database kept next to it, named with a
+// ".snap-" suffix followed by its ID.
+type Snapshot struct {
+	// ID identifies the snapshot to RestoreSnapshot.
+	ID   string
+	Time time.Time
+	Size int64
+}
+
+// ErrSnapshotNotFound is returned by RestoreSnapshot for an unknown ID.
+var ErrSnapshotNotFound = errors.New("jsondb: snapshot not found")
+
+func (db *DB[T]) snapshotPrefix() string {
+	return filepath.Base(db.path) + ".snap-"
+}
+
+func (db *DB[T]) snapshotPath(id string) string {
+	return filepath.Join(filepath.Dir(db.path), db.snapshotPrefix()+id)
+}
+
+// Snapshots returns the snapshots of the database, newest first.
+func (db *DB[T]) Snapshots() ([]Snapshot, error) {
+	return db.snapshots()
+}
+
+func (db *DB[T]) snapshots() ([]Snapshot, error) {
+	des, err := db.st.ReadDir(filepath.Dir(db.path))
+	if err != nil {
+		return nil, err
+	}
+	prefix := db.snapshotPrefix()
+	var snaps []Snapshot
+	for _, de := range des {
+		id, ok := strings.CutPrefix(de.Name(), prefix)
+		if !ok || !de.Type().IsRegular() {
+			continue
+		}
+		t, err := time.Parse(snapshotLayout, id)
+		if err != nil {
+			continue
+		}
+		fi, err := de.Info()
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, Snapshot{ID: id, Time: t, Size: fi.Size()})
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].ID > snaps[j].ID })
+	return snaps, nil
+}
+
+// snapshot saves the persisted contents of the database, the ones the
+// next write replaces, as a new snapshot if snapshots are enabled and
+// Options.BackupInterval has passed since the last one, or if force is
+// set. It returns the name of the snapshot file written, if any.
+func (db *DB[T]) snapshot(force bool) (name string, err error) {
+	if db.opts.Backups <= 0 {
+		return "", nil
+	}
+	now := time.Now().UTC()
+	if db.lastSnap.IsZero() {
+		if snaps, err := db.snapshots(); err == nil && len(snaps) > 0 {
+			db.lastSnap = snaps[0].Time
+		}
+	}
+	if !force && !db.lastSnap.IsZero() && now.Sub(db.lastSnap) < db.opts.BackupInterval {
+		return "", nil
+	}
+	bs, err := db.persisted()
+	if err != nil || bs == nil {
+		return "", err
+	}
+	name = db.snapshotPath(now.Format(snapshotLayout))
+	if err := db.st.WriteFile(name, bs, 0600); err != nil {
+		return "", err
+	}
+	db.lastSnap = now
+	return name, nil
+}
+
+// persisted returns the encoded contents of the database as last read
+// or written, or nil if there is no database file.
+func (db *DB[T]) persisted() ([]byte, error) {
+	if db.fi == nil {
+		return nil, nil
+	}
+	if db.j.size > 0 {
+		// The main file lacks the journaled changes, so encode the
+		// journal's view of the data instead.
+		js, err := json.Marshal(db.j.doc)
+		if err != nil {
+			return nil, err
+		}
+		val := new(T)
+		if err := json.Unmarshal(js, val); err != nil {
+			return nil, err
+		}
+		return db.encode(val)
+	}
+	bs, err := db.st.ReadFile(db.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return bs, err
+}
+
+// pruneSnapshots removes the oldest snapshots beyond Options.Backups.
+func (db *DB[T]) pruneSnapshots() error {
+	snaps, err := db.snapshots()
+	if err != nil {
+		return err
+	}
+	for i := db.opts.Backups; i < len(snaps); i++ {
+		if err := db.st.Remove(db.snapshotPath(snaps[i].ID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreSnapshot replaces the contents of the database with the
+// snapshot id. If snapshots are enabled, the current contents are
+// snapshotted first so that the restore can itself be undone.
+func (db *DB[T]) RestoreSnapshot(id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	unlock, err := db.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := time.Parse(snapshotLayout, id); err != nil {
+		return ErrSnapshotNotFound
+	}
+	bs, err := db.st.ReadFile(db.snapshotPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrSnapshotNotFound
+	} else if err != nil {
+		return err
+	}
+	val, _, err := db.decode(bs, nil)
+	if err != nil {
+		return fmt.Errorf("jsondb: reading snapshot %s: %w", id, err)
+	}
+	if err := db.reloadLocked(); err != nil {
+		return err
+	}
+	lastSnap := db.lastSnap
+	snap, err := db.snapshot(true)
+	if err != nil {
+		return err
+	}
+	if err := db.compact(val); err != nil {
+		if snap != "" {
+			db.st.Remove(snap)
+			db.lastSnap = lastSnap
+		}
+		return err
+	}
+	if db.opts.Backups > 0 {
+		db.pruneSnapshots()
+	}
+	db.Data = val
+	db.notify(EventCommit, nil)
+	return nil
+}
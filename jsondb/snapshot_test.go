@@ -0,0 +1,159 @@
+package jsondb
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	db, err := OpenOptions[testDB](path, Options{Backups: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		if err := db.Update(func(d *testDB) error { d.AnInt = int64(i); return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snaps, err := db.Snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 3 {
+		t.Fatalf("got %d snapshots, want 3", len(snaps))
+	}
+	for i := 1; i < len(snaps); i++ {
+		if !snaps[i].Time.Before(snaps[i-1].Time) {
+			t.Errorf("snapshots not sorted newest first: %v", snaps)
+		}
+	}
+
+	// Each snapshot holds the contents an update replaced; snaps[2] is
+	// the oldest retained one, taken before the third update.
+	if err := db.RestoreSnapshot(snaps[2].ID); err != nil {
+		t.Fatal(err)
+	}
+	if db.Data.AnInt != 2 {
+		t.Errorf("AnInt after restore = %d, want 2", db.Data.AnInt)
+	}
+	db2, err := Open[testDB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db2.Data.AnInt != 2 {
+		t.Errorf("saved AnInt after restore = %d, want 2", db2.Data.AnInt)
+	}
+
+	// The restore snapshotted the state it replaced.
+	snaps, err = db.Snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RestoreSnapshot(snaps[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if db.Data.AnInt != 5 {
+		t.Errorf("AnInt after undoing restore = %d, want 5", db.Data.AnInt)
+	}
+
+	if err := db.RestoreSnapshot("nope"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("RestoreSnapshot(unknown) = %v, want ErrSnapshotNotFound", err)
+	}
+}
+
+func TestSnapshotFailure(t *testing.T) {
+	var failSnap, failMain, failPrune bool
+	st := &failingStorage{Storage: NewMemStorage(), fail: func(op, name string) bool {
+		switch {
+		case !strings.Contains(name, ".snap-"):
+			return failMain
+		case op == "remove":
+			return failPrune
+		default:
+			return failSnap
+		}
+	}}
+	opts := Options{Storage: st, Backups: 1}
+	db, err := OpenOptions[testDB]("db.json", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := func(i int64) error {
+		return db.Update(func(d *testDB) error { d.AnInt = i; return nil })
+	}
+	for i := int64(1); i <= 2; i++ {
+		if err := update(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A failed snapshot fails the write before anything is committed.
+	failSnap = true
+	if err := update(3); err == nil {
+		t.Fatal("Update succeeded with failing snapshot")
+	}
+	failSnap = false
+	if db2, err := OpenOptions[testDB]("db.json", opts); err != nil || db2.Data.AnInt != 2 {
+		t.Fatalf("after failed snapshot, disk holds %+v, %v; want AnInt 2", db2, err)
+	}
+
+	// A failed commit or restore leaves no extra snapshot behind.
+	failMain = true
+	if err := update(4); err == nil {
+		t.Fatal("Update succeeded with failing database file")
+	}
+	snaps, err := db.Snapshots()
+	if err != nil || len(snaps) != 1 {
+		t.Fatalf("after failed commit, Snapshots = %v, %v; want 1 snapshot", snaps, err)
+	}
+	if err := db.RestoreSnapshot(snaps[0].ID); err == nil {
+		t.Fatal("RestoreSnapshot succeeded with failing database file")
+	}
+	failMain = false
+	if snaps, err := db.Snapshots(); err != nil || len(snaps) != 1 {
+		t.Fatalf("after failed restore, Snapshots = %v, %v; want 1 snapshot", snaps, err)
+	}
+
+	// Once the database file is written, failing to prune is not an error.
+	failPrune = true
+	if err := update(5); err != nil {
+		t.Fatalf("Update with failing prune: %v", err)
+	}
+	if db.Data.AnInt != 5 {
+		t.Errorf("AnInt = %d, want 5", db.Data.AnInt)
+	}
+}
+
+func TestSnapshotKeepsReplacedContents(t *testing.T) {
+	for _, journal := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "db.json")
+		db, err := OpenOptions[testDB](path, Options{Backups: 1, Journal: journal})
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Data.AnInt = 1
+		if err := db.Save(); err != nil {
+			t.Fatal(err)
+		}
+		db.Data.AnInt = 666
+		if err := db.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The only snapshot still holds the contents before the bad save.
+		snaps, err := db.Snapshots()
+		if err != nil || len(snaps) != 1 {
+			t.Fatalf("journal=%v: Snapshots = %v, %v; want 1 snapshot", journal, snaps, err)
+		}
+		if err := db.RestoreSnapshot(snaps[0].ID); err != nil {
+			t.Fatal(err)
+		}
+		if db.Data.AnInt != 1 {
+			t.Errorf("journal=%v: AnInt after restore = %d, want 1", journal, db.Data.AnInt)
+		}
+	}
+}
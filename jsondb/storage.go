@@ -0,0 +1,254 @@
+package jsondb
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage is the file system a DB keeps its files in: the database
+// itself and its sidecars (journal, checksums, snapshots and backups),
+// all in the directory of the database path.
+type Storage interface {
+	// ReadFile returns the contents of the named file.
+	ReadFile(name string) ([]byte, error)
+
+	// Stat returns information about the named file.
+	Stat(name string) (fs.FileInfo, error)
+
+	// ReadDir returns the entries of the named directory.
+	ReadDir(name string) ([]fs.DirEntry, error)
+
+	// WriteFile atomically replaces the named file with data: after a
+	// crash, the file has either its old or its new contents.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+
+	// WriteAt truncates the named file to off bytes, creating it if
+	// necessary, writes data at off and flushes it to stable storage.
+	WriteAt(name string, data []byte, off int64, perm fs.FileMode) error
+
+	// Rename renames a file.
+	Rename(oldname, newname string) error
+
+	// Remove removes the named file.
+	Remove(name string) error
+}
+
+// ErrReadOnly is returned when writing to read-only storage.
+var ErrReadOnly = errors.New("jsondb: storage is read-only")
+
+// OSStorage returns the Storage backed by the operating system's file
+// system. It is the default.
+func OSStorage() Storage {
+	return osStorage{}
+}
+
+type osStorage struct{}
+
+func (osStorage) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+func (osStorage) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (osStorage) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+func (osStorage) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return writeFile(name, data, perm)
+}
+
+func (osStorage) WriteAt(name string, data []byte, off int64, perm fs.FileMode) error {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Truncate(off); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(data, off); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (osStorage) Rename(oldname, newname string) error { return os.Rename(oldname, newname) }
+
+func (osStorage) Remove(name string) error { return os.Remove(name) }
+
+// FSStorage returns read-only Storage backed by fsys, such as an
+// embed.FS holding defaults baked into the binary. Database paths are
+// converted to slash-separated fs.FS paths. A DB on read-only storage
+// can be read but not saved.
+func FSStorage(fsys fs.FS) Storage {
+	return fsStorage{fsys}
+}
+
+type fsStorage struct {
+	fsys fs.FS
+}
+
+func fsPath(name string) string {
+	return path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "/"))
+}
+
+func (s fsStorage) ReadFile(name string) ([]byte, error) { return fs.ReadFile(s.fsys, fsPath(name)) }
+
+func (s fsStorage) Stat(name string) (fs.FileInfo, error) { return fs.Stat(s.fsys, fsPath(name)) }
+
+func (s fsStorage) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(s.fsys, fsPath(name))
+}
+
+func (fsStorage) WriteFile(string, []byte, fs.FileMode) error { return ErrReadOnly }
+
+func (fsStorage) WriteAt(string, []byte, int64, fs.FileMode) error { return ErrReadOnly }
+
+func (fsStorage) Rename(string, string) error { return ErrReadOnly }
+
+func (fsStorage) Remove(string) error { return ErrReadOnly }
+
+// MemStorage is Storage held in memory, for tests. The zero value is
+// an empty file system in which every directory exists. It is safe for
+// concurrent use.
+type MemStorage struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+}
+
+// NewMemStorage returns an empty MemStorage.
+func NewMemStorage() *MemStorage {
+	return new(MemStorage)
+}
+
+// memFile is one version of a file. WriteFile replaces it; WriteAt
+// modifies it in place.
+type memFile struct {
+	name    string
+	data    []byte
+	perm    fs.FileMode
+	modTime time.Time
+}
+
+// memFileInfo is a snapshot of a memFile's metadata. Sys returns the
+// memFile, which identifies the file across renames like an inode.
+type memFileInfo struct {
+	f       *memFile
+	size    int64
+	modTime time.Time
+}
+
+func (fi memFileInfo) Name() string       { return path.Base(fi.f.name) }
+func (fi memFileInfo) Size() int64        { return fi.size }
+func (fi memFileInfo) Mode() fs.FileMode  { return fi.f.perm }
+func (fi memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi memFileInfo) IsDir() bool        { return false }
+func (fi memFileInfo) Sys() any           { return fi.f }
+
+func (f *memFile) info() fs.FileInfo {
+	return memFileInfo{f: f, size: int64(len(f.data)), modTime: f.modTime}
+}
+
+func memKey(name string) string {
+	return filepath.ToSlash(filepath.Clean(name))
+}
+
+func (s *MemStorage) ReadFile(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[memKey(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (s *MemStorage) Stat(name string) (fs.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[memKey(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return f.info(), nil
+}
+
+func (s *MemStorage) ReadDir(name string) ([]fs.DirEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := memKey(name)
+	var des []fs.DirEntry
+	for key, f := range s.files {
+		if path.Dir(key) == dir {
+			des = append(des, fs.FileInfoToDirEntry(f.info()))
+		}
+	}
+	sort.Slice(des, func(i, j int) bool { return des[i].Name() < des[j].Name() })
+	return des, nil
+}
+
+func (s *MemStorage) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil {
+		s.files = make(map[string]*memFile)
+	}
+	key := memKey(name)
+	s.files[key] = &memFile{
+		name:    key,
+		data:    append([]byte(nil), data...),
+		perm:    perm,
+		modTime: time.Now(),
+	}
+	return nil
+}
+
+func (s *MemStorage) WriteAt(name string, data []byte, off int64, perm fs.FileMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil {
+		s.files = make(map[string]*memFile)
+	}
+	key := memKey(name)
+	f, ok := s.files[key]
+	if !ok {
+		f = &memFile{name: key, perm: perm}
+		s.files[key] = f
+	}
+	if off > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, off-int64(len(f.data)))...)
+	}
+	f.data = append(f.data[:off:off], data...)
+	f.modTime = time.Now()
+	return nil
+}
+
+func (s *MemStorage) Rename(oldname, newname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[memKey(oldname)]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	delete(s.files, memKey(oldname))
+	f.name = memKey(newname)
+	s.files[f.name] = f
+	return nil
+}
+
+func (s *MemStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[memKey(name)]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(s.files, memKey(name))
+	return nil
+}
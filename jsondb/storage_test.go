@@ -0,0 +1,108 @@
+package jsondb
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMemStorage(t *testing.T) {
+	st := NewMemStorage()
+	opts := Options{Storage: st, Journal: true, Backups: 2, Checksum: true, Recover: true}
+	db, err := OpenOptions[testDB]("/data/db.json", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := db.Update(func(d *testDB) error { d.AnInt = int64(i); return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := st.Stat("/data/db.json.wal"); err != nil {
+		t.Errorf("journal not written to storage: %v", err)
+	}
+	snaps, err := db.Snapshots()
+	if err != nil || len(snaps) != 2 {
+		t.Errorf("Snapshots = %v, %v; want 2", snaps, err)
+	}
+
+	// Another DB on the same storage sees the changes and can replace
+	// them; Reload picks that up.
+	db2, err := OpenOptions[testDB]("/data/db.json", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db2.Data.AnInt != 3 {
+		t.Errorf("AnInt = %d, want 3", db2.Data.AnInt)
+	}
+	if err := db2.Update(func(d *testDB) error { d.AnInt = 4; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if db.Data.AnInt != 4 {
+		t.Errorf("AnInt after Reload = %d, want 4", db.Data.AnInt)
+	}
+
+	// Corruption is detected and recovered from a snapshot.
+	if err := st.WriteFile("/data/db.json", []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	db3, err := OpenOptions[testDB]("/data/db.json", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db3.Data.AnInt == 0 {
+		t.Error("not recovered from snapshot")
+	}
+}
+
+func TestFSStorage(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults/db.json": {Data: []byte(`{"MyString":"default","AnInt":7}`)},
+	}
+	opts := Options{Storage: FSStorage(fsys)}
+	db, err := OpenOptions[testDB]("defaults/db.json", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Data.MyString != "default" || db.Data.AnInt != 7 {
+		t.Errorf("Data = %+v", db.Data)
+	}
+	if err := db.Save(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Save = %v, want ErrReadOnly", err)
+	}
+	if err := db.Reload(); err != nil {
+		t.Errorf("Reload: %v", err)
+	}
+
+	missing, err := OpenOptions[testDB]("defaults/missing.json", opts)
+	if err != nil || missing.Data.AnInt != 0 {
+		t.Errorf("opening missing file = %+v, %v", missing, err)
+	}
+
+	opts.Lock = LockShared
+	if _, err := OpenOptions[testDB]("defaults/db.json", opts); err == nil {
+		t.Error("Lock with FSStorage succeeded")
+	}
+}
+
+func TestFSStorageMigration(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults/db.json": {Data: []byte(`{"name":"web","port":80}`)},
+	}
+	db, err := OpenOptions[migratedDB]("defaults/db.json", Options{Storage: FSStorage(fsys)})
+	if err != nil {
+		t.Fatalf("opening version 0 DB: %v", err)
+	}
+	want := &migratedDB{Name: "web", Ports: []int{80}}
+	if diff := cmp.Diff(db.Data, want); diff != "" {
+		t.Errorf("unexpected migrated DB content (-got+want):\n%s", diff)
+	}
+	if err := db.Reload(); err != nil {
+		t.Errorf("Reload: %v", err)
+	}
+}
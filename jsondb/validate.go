@@ -0,0 +1,504 @@
+package jsondb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Validator is a database type that checks its own contents. If T or
+// *T implements Validator, Open and every write call Validate and fail
+// if it returns an error.
+type Validator interface {
+	Validate() error
+}
+
+// A FieldError describes one failed check.
+type FieldError struct {
+	// Path is the JSON Pointer (RFC 6901) of the offending value, or
+	// "" for the whole document.
+	Path    string
+	Message string
+}
+
+func (e FieldError) String() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// A ValidationError lists the checks that the contents of a database
+// failed. A Validator may return one to report several fields.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.String()
+	}
+	return "jsondb: validation failed: " + strings.Join(msgs, "; ")
+}
+
+// validate checks val against the Validator implementation of T and the
+// schema, if any. doc is the JSON document val was decoded from, or nil
+// to check the JSON encoding of val.
+func (db *DB[T]) validate(val *T, doc any) error {
+	var errs []FieldError
+	v, ok := any(val).(Validator)
+	if !ok {
+		v, ok = any(*val).(Validator)
+	}
+	if ok {
+		if err := v.Validate(); err != nil {
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				return &ValidationError{Errors: []FieldError{{Message: err.Error()}}}
+			}
+			errs = append(errs, verr.Errors...)
+		}
+	}
+	if db.schema != nil {
+		if doc == nil {
+			var err error
+			if doc, err = toDoc(val); err != nil {
+				return err
+			}
+		}
+		db.schema.check(&errs, "", doc)
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// schema is a compiled JSON Schema. It supports the validation keywords
+// type, enum, const, properties, required, additionalProperties, items,
+// minItems, maxItems, uniqueItems, minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, multipleOf, minLength, maxLength, pattern, allOf,
+// anyOf, oneOf and not, and ignores the annotations listed in
+// schemaAnnotations. Other keywords, including $ref, and keywords with a
+// value of the wrong type, such as the boolean exclusiveMinimum of draft
+// 4, are rejected rather than ignored, so that a schema is never silently
+// weaker than written.
+// Patterns use Go's regexp syntax.
+type schema struct {
+	types            []string
+	enum             []any
+	constant         *any
+	properties       map[string]*schema
+	required         []string
+	additional       *schema // nil allows anything unless noAdditional
+	noAdditional     bool
+	items            *schema
+	minItems         *int
+	maxItems         *int
+	uniqueItems      bool
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       json.Number
+	minLength        *int
+	maxLength        *int
+	pattern          *regexp.Regexp
+	allOf            []*schema
+	anyOf            []*schema
+	oneOf            []*schema
+	not              *schema
+	alwaysFalse      bool
+}
+
+// schemaKeywords are the keywords compileSchemaDoc implements.
+var schemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true, "uniqueItems": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	"multipleOf": true, "minLength": true, "maxLength": true, "pattern": true,
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true,
+}
+
+// schemaAnnotations are keywords that do not affect validation.
+var schemaAnnotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true,
+	"title": true, "description": true, "default": true, "examples": true,
+	"readOnly": true, "writeOnly": true, "deprecated": true,
+}
+
+// compileSchema parses a JSON Schema document.
+func compileSchema(bs []byte) (*schema, error) {
+	doc, err := parseDoc(bs)
+	if err != nil {
+		return nil, fmt.Errorf("jsondb: parsing schema: %w", err)
+	}
+	s, err := compileSchemaDoc(doc)
+	if err != nil {
+		return nil, fmt.Errorf("jsondb: compiling schema: %w", err)
+	}
+	return s, nil
+}
+
+func compileSchemaDoc(doc any) (*schema, error) {
+	if b, ok := doc.(bool); ok {
+		return &schema{alwaysFalse: !b}, nil
+	}
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("schema must be an object or boolean, got %s", mustRaw(doc))
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !schemaKeywords[k] && !schemaAnnotations[k] {
+			return nil, fmt.Errorf("unsupported keyword %q", k)
+		}
+	}
+	s := new(schema)
+	var err error
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, e := range t {
+			str, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid type %s", mustRaw(e))
+			}
+			s.types = append(s.types, str)
+		}
+	default:
+		return nil, fmt.Errorf("invalid type %s", mustRaw(t))
+	}
+	if e, ok := m["enum"]; ok {
+		if s.enum, ok = e.([]any); !ok {
+			return nil, fmt.Errorf("invalid enum %s", mustRaw(e))
+		}
+	}
+	if c, ok := m["const"]; ok {
+		s.constant = &c
+	}
+	if p, ok := m["properties"]; ok {
+		props, ok := p.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid properties %s", mustRaw(p))
+		}
+		s.properties = make(map[string]*schema, len(props))
+		for name, p := range props {
+			if s.properties[name], err = compileSchemaDoc(p); err != nil {
+				return nil, fmt.Errorf("property %q: %w", name, err)
+			}
+		}
+	}
+	if r, ok := m["required"]; ok {
+		req, ok := r.([]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid required %s", mustRaw(r))
+		}
+		for _, r := range req {
+			str, ok := r.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid required entry %s", mustRaw(r))
+			}
+			s.required = append(s.required, str)
+		}
+	}
+	if ap, ok := m["additionalProperties"]; ok {
+		if b, ok := ap.(bool); ok {
+			s.noAdditional = !b
+		} else if s.additional, err = compileSchemaDoc(ap); err != nil {
+			return nil, fmt.Errorf("additionalProperties: %w", err)
+		}
+	}
+	if items, ok := m["items"]; ok {
+		if s.items, err = compileSchemaDoc(items); err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+	}
+	if u, ok := m["uniqueItems"]; ok {
+		if s.uniqueItems, ok = u.(bool); !ok {
+			return nil, fmt.Errorf("invalid uniqueItems %s", mustRaw(u))
+		}
+	}
+	ints := map[string]**int{
+		"minItems": &s.minItems, "maxItems": &s.maxItems,
+		"minLength": &s.minLength, "maxLength": &s.maxLength,
+	}
+	for name, dst := range ints {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s %s", name, mustRaw(v))
+		}
+		i, err := n.Int64()
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid %s %s", name, n)
+		}
+		val := int(i)
+		*dst = &val
+	}
+	floats := map[string]**float64{
+		"minimum": &s.minimum, "maximum": &s.maximum,
+		"exclusiveMinimum": &s.exclusiveMinimum, "exclusiveMaximum": &s.exclusiveMaximum,
+	}
+	for name, dst := range floats {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		// Draft 4 boolean exclusiveMinimum and exclusiveMaximum are not
+		// supported and fail here.
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s %s", name, mustRaw(v))
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s", name, n)
+		}
+		*dst = &f
+	}
+	if v, ok := m["multipleOf"]; ok {
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("invalid multipleOf %s", mustRaw(v))
+		}
+		if r, ok := new(big.Rat).SetString(string(n)); !ok || r.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid multipleOf %s", n)
+		}
+		s.multipleOf = n
+	}
+	if v, ok := m["pattern"]; ok {
+		p, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid pattern %s", mustRaw(v))
+		}
+		if s.pattern, err = regexp.Compile(p); err != nil {
+			return nil, err
+		}
+	}
+	subs := map[string]*[]*schema{"allOf": &s.allOf, "anyOf": &s.anyOf, "oneOf": &s.oneOf}
+	for name, dst := range subs {
+		v, ok := m[name]
+		if !ok {
+			continue
+		}
+		list, ok := v.([]any)
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("invalid %s %s", name, mustRaw(v))
+		}
+		for i, e := range list {
+			sub, err := compileSchemaDoc(e)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%d: %w", name, i, err)
+			}
+			*dst = append(*dst, sub)
+		}
+	}
+	if not, ok := m["not"]; ok {
+		if s.not, err = compileSchemaDoc(not); err != nil {
+			return nil, fmt.Errorf("not: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// isMultiple reports whether n is an integer multiple of m, using exact
+// decimal arithmetic so that 0.3 is a multiple of 0.1.
+func isMultiple(n, m json.Number) bool {
+	rn, ok1 := new(big.Rat).SetString(string(n))
+	rm, ok2 := new(big.Rat).SetString(string(m))
+	return ok1 && ok2 && rn.Quo(rn, rm).IsInt()
+}
+
+// typeOf returns the JSON Schema type name of a generic JSON value.
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// valid reports whether v satisfies s.
+func (s *schema) valid(v any) bool {
+	var errs []FieldError
+	s.check(&errs, "", v)
+	return len(errs) == 0
+}
+
+// check appends to errs a FieldError for every constraint of s that v,
+// located at path, violates.
+func (s *schema) check(errs *[]FieldError, path string, v any) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.alwaysFalse {
+		fail("value not allowed")
+		return
+	}
+	if len(s.types) > 0 {
+		t, ok := typeOf(v), false
+		for _, want := range s.types {
+			if want == t || (want == "number" && t == "integer") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("expected %s, got %s", strings.Join(s.types, " or "), t)
+			return
+		}
+	}
+	if s.enum != nil {
+		ok := false
+		for _, e := range s.enum {
+			if equalDoc(v, e) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("must be one of %s", mustRaw(s.enum))
+		}
+	}
+	if s.constant != nil && !equalDoc(v, *s.constant) {
+		fail("must be %s", mustRaw(*s.constant))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p := path + "/" + escapePointer(name)
+			if ps, ok := s.properties[name]; ok {
+				ps.check(errs, p, v[name])
+			} else if s.noAdditional {
+				*errs = append(*errs, FieldError{Path: p, Message: "unexpected property"})
+			} else if s.additional != nil {
+				s.additional.check(errs, p, v[name])
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+		if s.uniqueItems {
+			for i := range v {
+				for j := 0; j < i; j++ {
+					if equalDoc(v[i], v[j]) {
+						fail("items %d and %d are equal", j, i)
+					}
+				}
+			}
+		}
+		if s.items != nil {
+			for i, e := range v {
+				s.items.check(errs, fmt.Sprintf("%s/%d", path, i), e)
+			}
+		}
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			fail("invalid number %s", v)
+			break
+		}
+		if s.minimum != nil && f < *s.minimum {
+			fail("must be >= %v", *s.minimum)
+		}
+		if s.maximum != nil && f > *s.maximum {
+			fail("must be <= %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+			fail("must be > %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+			fail("must be < %v", *s.exclusiveMaximum)
+		}
+		if s.multipleOf != "" && !isMultiple(v, s.multipleOf) {
+			fail("must be a multiple of %s", s.multipleOf)
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			fail("must be at least %d characters long", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("must be at most %d characters long", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match %q", s.pattern)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.check(errs, path, v)
+	}
+	if len(s.anyOf) > 0 {
+		ok := false
+		for _, sub := range s.anyOf {
+			if sub.valid(v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("must match at least one schema in anyOf")
+		}
+	}
+	if len(s.oneOf) > 0 {
+		n := 0
+		for _, sub := range s.oneOf {
+			if sub.valid(v) {
+				n++
+			}
+		}
+		if n != 1 {
+			fail("must match exactly one schema in oneOf, matched %d", n)
+		}
+	}
+	if s.not != nil && s.not.valid(v) {
+		fail("must not match schema in not")
+	}
+}
@@ -0,0 +1,195 @@
+package jsondb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type server struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+type serverConfig struct {
+	Servers []server `json:"servers"`
+	Mode    string   `json:"mode,omitempty"`
+}
+
+func (c *serverConfig) Validate() error {
+	if c.Mode == "forbidden" {
+		return errors.New("mode forbidden")
+	}
+	return nil
+}
+
+const serverSchema = `{
+	"type": "object",
+	"required": ["servers"],
+	"additionalProperties": false,
+	"properties": {
+		"servers": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["host", "port"],
+				"properties": {
+					"host": {"type": "string", "minLength": 1},
+					"port": {"type": "integer", "minimum": 1, "maximum": 65535}
+				}
+			}
+		},
+		"mode": {"enum": ["active", "standby", "forbidden"]}
+	}
+}`
+
+func TestValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	opts := Options{Schema: []byte(serverSchema)}
+	db, err := OpenOptions[serverConfig](path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(c *serverConfig) error {
+		c.Servers = []server{{"a", 80}}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(c *serverConfig) error {
+		c.Servers = append(c.Servers, server{"", 70000})
+		return nil
+	})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("invalid Update = %v, want ValidationError", err)
+	}
+	want := []FieldError{
+		{Path: "/servers/1/host", Message: "must be at least 1 characters long"},
+		{Path: "/servers/1/port", Message: "must be <= 65535"},
+	}
+	if diff := cmp.Diff(verr.Errors, want); diff != "" {
+		t.Errorf("unexpected validation errors (-got+want):\n%s", diff)
+	}
+	if len(db.Data.Servers) != 1 {
+		t.Errorf("invalid Update was applied: %+v", db.Data)
+	}
+
+	err = db.Update(func(c *serverConfig) error { c.Mode = "forbidden"; return nil })
+	if !errors.As(err, &verr) || verr.Errors[0].Message != "mode forbidden" {
+		t.Errorf("Update rejected by Validate = %v", err)
+	}
+
+	// Hand-edited into a state json.Unmarshal accepts.
+	if err := os.WriteFile(path, []byte(`{"servers":[{"host":"a"}],"extra":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenOptions[serverConfig](path, opts)
+	if !errors.As(err, &verr) {
+		t.Fatalf("opening invalid file = %v, want ValidationError", err)
+	}
+	want = []FieldError{
+		{Path: "/extra", Message: "unexpected property"},
+		{Path: "/servers/0", Message: `missing required property "port"`},
+	}
+	if diff := cmp.Diff(verr.Errors, want); diff != "" {
+		t.Errorf("unexpected validation errors (-got+want):\n%s", diff)
+	}
+}
+
+func TestSchemaKeywords(t *testing.T) {
+	tests := []struct {
+		schema, doc string
+		valid       bool
+	}{
+		{`{"type":["string","null"]}`, `null`, true},
+		{`{"type":"integer"}`, `1.5`, false},
+		{`{"type":"number","multipleOf":0.5}`, `1.5`, true},
+		{`{"multipleOf":0.1}`, `0.3`, true},
+		{`{"multipleOf":0.1}`, `0.35`, false},
+		{`{"multipleOf":0.01}`, `19.99`, true},
+		{`{"title":"t","description":"d","default":1,"minimum":0}`, `1`, true},
+		{`{"exclusiveMinimum":0}`, `0`, false},
+		{`{"pattern":"^[a-z]+$"}`, `"abc"`, true},
+		{`{"pattern":"^[a-z]+$"}`, `"ab1"`, false},
+		{`{"const":{"a":[1]}}`, `{"a":[1.0]}`, true},
+		{`{"uniqueItems":true}`, `[1,2,1]`, false},
+		{`{"maxItems":1}`, `[1,2]`, false},
+		{`{"anyOf":[{"type":"string"},{"minimum":3}]}`, `2`, false},
+		{`{"oneOf":[{"minimum":1},{"minimum":2}]}`, `3`, false},
+		{`{"not":{"type":"null"}}`, `null`, false},
+		{`{"allOf":[{"minimum":1},{"maximum":3}]}`, `2`, true},
+		{`{"additionalProperties":{"type":"string"}}`, `{"a":1}`, false},
+		{`{"items":false}`, `[]`, true},
+		{`{"items":false}`, `[1]`, false},
+	}
+	for _, tt := range tests {
+		s, err := compileSchema([]byte(tt.schema))
+		if err != nil {
+			t.Fatalf("compileSchema(%s): %v", tt.schema, err)
+		}
+		doc, err := parseDoc([]byte(tt.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.valid(doc); got != tt.valid {
+			t.Errorf("schema %s, doc %s: valid = %v, want %v", tt.schema, tt.doc, got, tt.valid)
+		}
+	}
+}
+
+func TestSchemaUnsupported(t *testing.T) {
+	tests := []string{
+		`{"$ref":"#/definitions/port"}`,
+		`{"definitions":{"port":{"type":"integer"}}}`,
+		`{"patternProperties":{"^x-":{"type":"string"}}}`,
+		`{"properties":{"email":{"type":"string","format":"email"}}}`,
+		`{"items":{"if":{"type":"string"}}}`,
+		`{"multipleOf":0}`,
+	}
+	for _, schema := range tests {
+		if _, err := compileSchema([]byte(schema)); err == nil {
+			t.Errorf("compileSchema(%s) succeeded, want error", schema)
+		}
+	}
+}
+
+func TestSchemaInvalidKeyword(t *testing.T) {
+	tests := []string{
+		`{"type":"object","required":"MyString","minimum":"5","properties":[]}`,
+		`{"type":5}`,
+		`{"enum":"a"}`,
+		`{"properties":[]}`,
+		`{"properties":{"a":5}}`,
+		`{"required":"x"}`,
+		`{"required":[5]}`,
+		`{"additionalProperties":"no"}`,
+		`{"items":[{"type":"string"}]}`,
+		`{"uniqueItems":"true"}`,
+		`{"minItems":"1"}`,
+		`{"maxItems":1.5}`,
+		`{"minLength":-1}`,
+		`{"maxLength":null}`,
+		`{"minimum":"5"}`,
+		`{"maximum":[5]}`,
+		`{"exclusiveMinimum":true}`,
+		`{"exclusiveMaximum":false}`,
+		`{"multipleOf":"2"}`,
+		`{"pattern":5}`,
+		`{"pattern":"("}`,
+		`{"allOf":{}}`,
+		`{"anyOf":[]}`,
+		`{"oneOf":[5]}`,
+		`{"not":"x"}`,
+	}
+	for _, schema := range tests {
+		if _, err := compileSchema([]byte(schema)); err == nil {
+			t.Errorf("compileSchema(%s) succeeded, want error", schema)
+		}
+	}
+}
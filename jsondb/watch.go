@@ -0,0 +1,155 @@
+package jsondb
+
+import (
+	"errors"
+	"sync"
+)
+
+// EventType describes what caused an Event.
+type EventType int
+
+const (
+	// EventCommit is sent after Save or Update wrote the database.
+	EventCommit EventType = iota
+
+	// EventReload is sent after the database was reloaded because the
+	// file was changed by another process or edited by hand.
+	EventReload
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventCommit:
+		return "commit"
+	case EventReload:
+		return "reload"
+	}
+	return "unknown"
+}
+
+// An Event describes a change to the contents of a DB.
+type Event[T any] struct {
+	Type EventType
+
+	// Before and After are copies of the contents before and after the
+	// change. They are shared between subscribers and must not be
+	// modified.
+	Before, After *T
+
+	// Err is set if the watcher noticed a change to the file but could
+	// not load it, for instance because it is being edited. The
+	// contents of the DB are unchanged and After equals Before.
+	Err error
+}
+
+// subBufferSize is the number of events buffered for each subscriber.
+// Events are dropped for subscribers that fall further behind.
+const subBufferSize = 16
+
+// notifier fans out events to subscribers.
+type notifier[T any] struct {
+	mu   sync.Mutex
+	subs map[chan Event[T]]struct{}
+	last *T // copy of the contents as last sent, nil without subscribers
+}
+
+// Subscribe returns a channel that receives an Event for every change to
+// the database, and a function that cancels the subscription and closes
+// the channel. Events are delivered without blocking writers; a
+// subscriber that does not keep up misses events.
+//
+// Changes made by other processes are only observed when the DB is
+// reloaded, either explicitly or by the watcher enabled with
+// Options.Watch.
+func (db *DB[T]) Subscribe() (<-chan Event[T], func()) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	n := &db.notifier
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ch := make(chan Event[T], subBufferSize)
+	if n.subs == nil {
+		n.subs = make(map[chan Event[T]]struct{})
+	}
+	if len(n.subs) == 0 {
+		n.last, _ = db.clone(db.Data)
+	}
+	n.subs[ch] = struct{}{}
+	if db.closed {
+		n.closeLocked()
+	}
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if _, ok := n.subs[ch]; ok {
+				delete(n.subs, ch)
+				close(ch)
+			}
+			if len(n.subs) == 0 {
+				n.last = nil
+			}
+		})
+	}
+}
+
+// notify sends an event of type typ to subscribers. db.mu must be held.
+func (db *DB[T]) notify(typ EventType, err error) {
+	n := &db.notifier
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.subs) == 0 {
+		return
+	}
+	ev := Event[T]{Type: typ, Before: n.last, After: n.last, Err: err}
+	if err == nil {
+		after, err := db.clone(db.Data)
+		if err != nil {
+			return
+		}
+		ev.After = after
+		n.last = after
+	}
+	for ch := range n.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// closeLocked closes all subscriber channels. n.mu must be held.
+func (n *notifier[T]) closeLocked() {
+	for ch := range n.subs {
+		delete(n.subs, ch)
+		close(ch)
+	}
+	n.last = nil
+}
+
+// A watcher waits for changes to a database file.
+type watcher interface {
+	// wait blocks until the file or its journal may have changed. It
+	// returns errWatcherClosed after close has been called.
+	wait() error
+	close() error
+}
+
+var errWatcherClosed = errors.New("jsondb: watcher closed")
+
+// watch reloads the database whenever w reports a change, until w is
+// closed.
+func (db *DB[T]) watch(w watcher) {
+	defer close(db.watchDone)
+	for {
+		if err := w.wait(); err != nil {
+			return
+		}
+		if err := db.Reload(); err != nil {
+			db.mu.RLock()
+			db.notify(EventReload, err)
+			db.mu.RUnlock()
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package jsondb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyWatcher watches the directory holding the database, since
+// writeFile replaces the file by renaming a new one over it.
+type inotifyWatcher struct {
+	f    *os.File
+	name string
+	buf  [64 * (syscall.SizeofInotifyEvent + syscall.NAME_MAX + 1)]byte
+}
+
+func newWatcher(path string) (watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE | syscall.IN_DELETE
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), mask); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+	// The descriptor is non-blocking, so os.File uses the runtime poller
+	// and Close interrupts a pending Read.
+	return &inotifyWatcher{
+		f:    os.NewFile(uintptr(fd), "inotify"),
+		name: filepath.Base(path),
+	}, nil
+}
+
+func (w *inotifyWatcher) wait() error {
+	for {
+		n, err := w.f.Read(w.buf[:])
+		if errors.Is(err, os.ErrClosed) {
+			return errWatcherClosed
+		} else if err != nil {
+			return err
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&w.buf[off]))
+			nameBytes := w.buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			name := strings.TrimRight(string(nameBytes), "\x00")
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+			if name == w.name || name == w.name+".wal" {
+				return nil
+			}
+		}
+	}
+}
+
+func (w *inotifyWatcher) close() error {
+	return w.f.Close()
+}
@@ -0,0 +1,38 @@
+//go:build !linux
+
+package jsondb
+
+import "time"
+
+// defaultWatchInterval is how often the database file is checked for
+// changes on platforms without inotify.
+const defaultWatchInterval = time.Second
+
+// pollWatcher reports a possible change at every tick; Reload then
+// compares the file with what was last read.
+type pollWatcher struct {
+	t    *time.Ticker
+	done chan struct{}
+}
+
+func newWatcher(path string) (watcher, error) {
+	return &pollWatcher{
+		t:    time.NewTicker(defaultWatchInterval),
+		done: make(chan struct{}),
+	}, nil
+}
+
+func (w *pollWatcher) wait() error {
+	select {
+	case <-w.t.C:
+		return nil
+	case <-w.done:
+		return errWatcherClosed
+	}
+}
+
+func (w *pollWatcher) close() error {
+	w.t.Stop()
+	close(w.done)
+	return nil
+}
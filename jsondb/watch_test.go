@@ -0,0 +1,66 @@
+package jsondb
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, ch <-chan Event[testDB]) Event[testDB] {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	panic("unreachable")
+}
+
+func TestSubscribe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	db, err := OpenOptions[testDB](path, Options{Watch: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ch, cancel := db.Subscribe()
+	defer cancel()
+
+	if err := db.Update(func(d *testDB) error { d.AnInt = 1; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	ev := nextEvent(t, ch)
+	if ev.Type != EventCommit || ev.Before.AnInt != 0 || ev.After.AnInt != 1 {
+		t.Fatalf("got %v event %d -> %d, want commit 0 -> 1", ev.Type, ev.Before.AnInt, ev.After.AnInt)
+	}
+
+	other, err := Open[testDB](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Data.AnInt = 2
+	if err := other.Save(); err != nil {
+		t.Fatal(err)
+	}
+	ev = nextEvent(t, ch)
+	if ev.Type != EventReload || ev.Err != nil || ev.Before.AnInt != 1 || ev.After.AnInt != 2 {
+		t.Fatalf("got %v event %d -> %d (err %v), want reload 1 -> 2", ev.Type, ev.Before.AnInt, ev.After.AnInt, ev.Err)
+	}
+	db.View(func(d *testDB) error {
+		if d.AnInt != 2 {
+			t.Errorf("AnInt after reload = %d, want 2", d.AnInt)
+		}
+		return nil
+	})
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("subscription still open after Close")
+	}
+}